@@ -0,0 +1,71 @@
+package xwebview
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/twgh/xwebview/internal/walk"
+)
+
+// MethodExcluder 可以由传给 BindObject 的对象实现, 用来排除不需要绑定的方法.
+// ExcludeMethods 返回不需要绑定的方法名, 使用 Go 中的方法名, 如 "Close".
+type MethodExcluder = walk.MethodExcluder
+
+// BindObject 把 obj 的所有导出方法绑定到 JavaScript 的 window.<namespace> 对象上. 必须在UI线程执行.
+//
+// 方法名会转为首字母小写的形式, 比如 MoveWindow 在js中为 window.namespace.moveWindow(...), 调用返回 Promise.
+// 方法的参数和返回值规则与 Bind 相同.
+//
+// namespace: 命名空间, 可以用点号分隔创建嵌套的命名空间, 如 "app.api".
+//
+// obj: 结构体指针或其他有方法的值. 实现了 MethodExcluder 接口时, 排除的方法不会被绑定.
+// 结构体中带有 `xwebview:"name"` 标签的导出字段, 会作为嵌套的命名空间 namespace.name 递归绑定.
+//
+// opt: 选项, 对所有方法生效, 可不填.
+//
+// 有方法无法绑定时返回错误, 已经绑定的方法会被解除.
+func (w *WebView) BindObject(namespace string, obj interface{}, opt ...BindOption) error {
+	if namespace == "" {
+		return errors.New("namespace 不能为空")
+	}
+	v := reflect.ValueOf(obj)
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return errors.New("obj 不能为 nil")
+	}
+
+	// 与 tsgen.Generator.AddObject 使用同样的规则, 生成的 TypeScript 声明与绑定的函数一致
+	var bound []string
+	err := walk.Object(namespace, obj, func(name string, method reflect.Value) error {
+		if err := w.Bind(name, method.Interface(), opt...); err != nil {
+			return err
+		}
+		bound = append(bound, name)
+		return nil
+	})
+	if err != nil {
+		// 不留下只绑定了一部分的命名空间
+		for _, name := range bound {
+			_ = w.Unbind(name)
+		}
+		return err
+	}
+	return nil
+}
+
+// UnbindObject 解除 BindObject 绑定的命名空间下的所有函数, 并删除页面中的 window.<namespace> 对象. 必须在UI线程执行.
+func (w *WebView) UnbindObject(namespace string) error {
+	prefix := namespace + "."
+	found := false
+	for _, name := range w.ListBindings() {
+		if strings.HasPrefix(name, prefix) {
+			_ = w.Unbind(name)
+			found = true
+		}
+	}
+	if !found {
+		return ErrBindingNotFound
+	}
+	w.browser.Eval("window._rpc.unbind(" + jsString(namespace) + ");")
+	return nil
+}
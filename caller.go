@@ -0,0 +1,82 @@
+package xwebview
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/twgh/xwebview/internal/origin"
+	"github.com/twgh/xwebview/pkg/edge"
+	"github.com/twgh/xwebview/pkg/tsgen"
+)
+
+// Caller 是调用绑定的函数的页面的信息.
+//
+// 函数的参数中可以声明 *Caller, 它由 xwebview 传入, 不占用 js 端的参数, 必须在 context.Context 之后, 其他参数之前, 与 Progress 的顺序不限.
+// 第一个参数是 context.Context 时, 也可以用 CallerFromContext 获取.
+type Caller struct {
+	// Origin 是页面的来源, 如 "https://example.com", 没有来源的页面(如 about:blank, data: URI)为 "null".
+	Origin string
+	// URI 是发送消息的文档的完整地址.
+	URI string
+	// IsMainFrame 表示消息来自与顶层文档同源的文档. 来源不同的调用会被拒绝, 所以函数收到的总是 true.
+	IsMainFrame bool
+	// NavigationID 是页面所属导航的 ID, 每次导航都不同.
+	NavigationID uint64
+}
+
+var callerType = reflect.TypeOf((*Caller)(nil))
+
+func init() {
+	tsgen.SkipParam(callerType)
+}
+
+type callerKey struct{}
+
+// CallerFromContext 返回绑定的函数收到的 context 中的调用者信息.
+func CallerFromContext(ctx context.Context) (*Caller, bool) {
+	c, ok := ctx.Value(callerKey{}).(*Caller)
+	return c, ok
+}
+
+// withCaller 返回带有调用者信息的 context.
+func withCaller(ctx context.Context, c *Caller) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// newCaller 根据 WebView2 的消息事件参数创建调用者信息. 必须在UI线程执行.
+func (w *WebView) newCaller(args *edge.ICoreWebView2WebMessageReceivedEventArgs) *Caller {
+	c := &Caller{Origin: origin.Null}
+	if args == nil {
+		return c
+	}
+	c.URI, _ = args.GetSource()
+	c.Origin = origin.Of(c.URI)
+	// WebMessageReceived 只由顶层文档触发, 这里只是防御性检查. 比较来源而不是完整的地址,
+	// 否则 history.pushState 或修改 hash 与调用同时发生时, 顶层文档的调用也会被拒绝
+	top, err := w.browser.Source()
+	c.IsMainFrame = err == nil && origin.Of(top) == c.Origin
+	w.callsMux.Lock()
+	c.NavigationID = w.navigationID
+	w.callsMux.Unlock()
+	return c
+}
+
+// checkCaller 检查调用者是否可以调用 b, b 为 nil 时只检查 XcWebViewOption.AllowedOrigins 和页面是否完成握手. c 为 nil 时不检查.
+func (w *WebView) checkCaller(c *Caller, b *binding) error {
+	if c == nil {
+		return nil
+	}
+	if !c.IsMainFrame {
+		return NewError(ErrCodeForbidden, "calls from iframes are not allowed")
+	}
+	if !w.protocolReady() {
+		return NewError(ErrCodeProtocol, "页面的 js 运行时没有完成握手")
+	}
+	if !origin.MatchAny(w.allowedOrigins, c.Origin) || (b != nil && !origin.MatchAny(b.allowedOrigins, c.Origin)) {
+		return NewError(ErrCodeForbidden, "origin not allowed: "+c.Origin)
+	}
+	return nil
+}
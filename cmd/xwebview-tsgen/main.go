@@ -0,0 +1,114 @@
+// xwebview-tsgen 为绑定到 WebView 的 Go 函数和结构体方法生成 TypeScript 声明文件.
+//
+// 它会在当前模块中生成一个临时程序, 导入指定的包, 通过反射生成声明, 所以需要在使用该包的模块目录下执行.
+//
+// 用法:
+//
+//	xwebview-tsgen -pkg example.com/app/api -func CalcMD5:calculateMD5,OpenFile -object Window:win -o bindings.d.ts
+//
+// -func 是包中的函数, 格式为 Go函数名[:js名称], 不填 js 名称时与 Go 函数名相同.
+// -object 是包中的类型, 格式为 类型名:命名空间, 与 WebView.BindObject 对应, 使用类型的指针获取方法.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	pkg := flag.String("pkg", "", "包含函数和类型的包的导入路径")
+	funcs := flag.String("func", "", "逗号分隔的函数, 格式为 Go函数名[:js名称]")
+	objects := flag.String("object", "", "逗号分隔的类型, 格式为 类型名:命名空间")
+	out := flag.String("o", "", "输出文件, 为空时输出到标准输出")
+	flag.Parse()
+
+	if err := run(*pkg, *funcs, *objects, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "xwebview-tsgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(pkg, funcs, objects, out string) error {
+	if pkg == "" {
+		return errors.New("缺少 -pkg")
+	}
+	src, err := program(pkg, funcs, objects)
+	if err != nil {
+		return err
+	}
+
+	// 临时程序必须在当前模块中, 才能导入用户的包
+	dir, err := os.MkdirTemp(".", "xwebview-tsgen-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), src, 0644); err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(stdout.Bytes())
+		return err
+	}
+	return os.WriteFile(out, stdout.Bytes(), 0644)
+}
+
+// program 生成临时程序的源码.
+func program(pkg, funcs, objects string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("package main\n\n")
+	b.WriteString("import (\n\t\"fmt\"\n\t\"os\"\n\n")
+	b.WriteString("\tpkg " + strconv.Quote(pkg) + "\n")
+	b.WriteString("\t\"github.com/twgh/xwebview/pkg/tsgen\"\n)\n\n")
+	b.WriteString("func check(err error) {\n\tif err != nil {\n\t\tfmt.Fprintln(os.Stderr, err)\n\t\tos.Exit(1)\n\t}\n}\n\n")
+	b.WriteString("func main() {\n\tg := tsgen.New()\n")
+
+	n := 0
+	for _, f := range split(funcs) {
+		goName, jsName := f, f
+		if i := strings.Index(f, ":"); i >= 0 {
+			goName, jsName = f[:i], f[i+1:]
+		}
+		b.WriteString("\tcheck(g.AddFunc(" + strconv.Quote(jsName) + ", pkg." + goName + "))\n")
+		n++
+	}
+	for _, o := range split(objects) {
+		i := strings.Index(o, ":")
+		if i < 0 {
+			return nil, errors.New("-object 的格式为 类型名:命名空间: " + o)
+		}
+		b.WriteString("\tcheck(g.AddObject(" + strconv.Quote(o[i+1:]) + ", new(pkg." + o[:i] + ")))\n")
+		n++
+	}
+	if n == 0 {
+		return nil, errors.New("缺少 -func 或 -object")
+	}
+	b.WriteString("\t_, err := g.WriteTo(os.Stdout)\n\tcheck(err)\n}\n")
+	return b.Bytes(), nil
+}
+
+func split(s string) []string {
+	var list []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
@@ -0,0 +1,106 @@
+package xwebview
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/twgh/xwebview/internal/bigint"
+	"github.com/twgh/xwebview/pkg/msgpack"
+)
+
+// Codec 是 Go 端与 js 运行时之间的消息的编码方式, 通过 XcWebViewOption.Codec 设置.
+//
+// js 运行时中必须有同名的实现 window._rpc.codecs[name] = {encode(value, replacer), decode(string)},
+// 内置的 CodecJSON, CodecJSONBigInt 和 CodecMsgpack 已经实现, 自定义的 Codec 需要通过 Init 注入 js 实现.
+// 页面加载时 js 运行时与 Go 端握手, 确认支持该编码方式后才开始使用它, 见 ProtocolVersion.
+type Codec interface {
+	// Name 是编码的名称, 与 js 运行时中的名称对应.
+	Name() string
+	// Encode 把发送给 js 的值编码为字符串.
+	Encode(v interface{}) (string, error)
+	// Decode 把 js 发来的消息转换为 json, 之后按 json 解码到函数的参数.
+	Decode(msg string) ([]byte, error)
+}
+
+var (
+	// CodecJSON 使用 encoding/json 和 JSON.stringify, 是默认的编码方式.
+	CodecJSON Codec = jsonCodec{}
+	// CodecJSONBigInt 与 CodecJSON 相同, 但是超出 js 安全整数范围的整数在 js 中是 BigInt, 不会丢失精度.
+	// js 端传入的 BigInt 可以解码到 int64 和 uint64.
+	CodecJSONBigInt Codec = jsonBigIntCodec{}
+	// CodecMsgpack 使用 MessagePack, 以 base64 传输. []byte 在 js 中是 Uint8Array, 大整数是 BigInt, 见 pkg/msgpack.
+	CodecMsgpack Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Encode(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (jsonCodec) Decode(msg string) ([]byte, error) {
+	return []byte(msg), nil
+}
+
+type jsonBigIntCodec struct{}
+
+func (jsonBigIntCodec) Name() string { return "json-bigint" }
+
+func (jsonBigIntCodec) Encode(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	b, err = bigint.Wrap(b)
+	return string(b), err
+}
+
+func (jsonBigIntCodec) Decode(msg string) ([]byte, error) {
+	return bigint.Unwrap([]byte(msg))
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Encode(v interface{}) (string, error) {
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func (msgpackCodec) Decode(msg string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(msg)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.ToJSON(b)
+}
+
+// jsValue 用 WebView 的 Codec 编码 v, 返回在 js 中求值得到 v 的表达式.
+func (w *WebView) jsValue(v interface{}) (string, error) {
+	s, err := w.codec.Encode(v)
+	if err != nil {
+		return "", err
+	}
+	if w.codec.Name() == "json" {
+		return s, nil
+	}
+	return "window._rpc.decode(" + jsString(s) + ")", nil
+}
+
+// jsError 返回在 js 中求值得到 err 对应的错误对象的表达式, 附加数据无法编码时会被丢弃.
+func (w *WebView) jsError(err error) string {
+	obj := toErrorObject(err)
+	s, e := w.jsValue(obj)
+	if e != nil {
+		obj.Data = nil
+		s, _ = w.jsValue(obj)
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package xwebview
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/twgh/xwebview/pkg/edge"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// callKey 标识一次 js 调用. js 端的调用 id 在每个页面中都从1开始, 所以需要加上页面序号.
+type callKey struct {
+	page uint64
+	id   int
+}
+
+// initContext 初始化 WebView 的 context, 在 WebView 销毁时取消.
+func (w *WebView) initContext() {
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.pageCtx, w.pageCancel = context.WithCancel(w.ctx)
+	w.calls = map[callKey]context.CancelFunc{}
+	w.streams = map[callKey]chan int{}
+	w.jsCalls = map[int]chan jsFuncResult{}
+	w.evalCalls = map[int]chan jsFuncResult{}
+	w.loaded = make(chan struct{})
+}
+
+// onContentLoading 在新文档开始加载时取消旧页面中所有未完成的调用, 并重置握手状态, 新文档的运行时会重新握手.
+//
+// 不在导航开始时处理: 导航可能被取消(beforeunload, NavigationStarting 中取消)或变为下载,
+// 这时旧页面仍然存在, 它的调用必须能收到结果, 也不会再次握手.
+func (w *WebView) onContentLoading(_ *edge.ICoreWebView2, args *edge.ICoreWebView2ContentLoadingEventArgs) {
+	id, _ := args.GetNavigationId()
+	w.callsMux.Lock()
+	w.pageCancel()
+	w.page++
+	w.navigationID = id
+	w.protocol = 0
+	w.pageCtx, w.pageCancel = context.WithCancel(w.ctx)
+	w.callsMux.Unlock()
+}
+
+// startCall 为 js 调用创建 context, 它在页面离开、WebView 销毁或 js 端取消调用时被取消.
+// 调用结束后必须执行返回的 done.
+func (w *WebView) startCall(id int) (ctx context.Context, page uint64, done func()) {
+	w.callsMux.Lock()
+	defer w.callsMux.Unlock()
+	page = w.page
+	ctx, cancel := context.WithCancel(w.pageCtx)
+	key := callKey{page: page, id: id}
+	w.calls[key] = cancel
+	return ctx, page, func() {
+		w.callsMux.Lock()
+		delete(w.calls, key)
+		w.callsMux.Unlock()
+		cancel()
+	}
+}
+
+// pageContext 返回当前页面的 context 和页面序号, 它在页面离开或 WebView 销毁时被取消.
+func (w *WebView) pageContext() (context.Context, uint64) {
+	w.callsMux.Lock()
+	defer w.callsMux.Unlock()
+	return w.pageCtx, w.page
+}
+
+// cancelCall 取消当前页面中 id 对应的调用.
+func (w *WebView) cancelCall(id int) {
+	w.callsMux.Lock()
+	cancel := w.calls[callKey{page: w.page, id: id}]
+	w.callsMux.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// isCurrentPage 判断 page 是否仍是当前页面.
+func (w *WebView) isCurrentPage(page uint64) bool {
+	w.callsMux.Lock()
+	defer w.callsMux.Unlock()
+	return w.page == page
+}
@@ -0,0 +1,96 @@
+package xwebview
+
+import (
+	"errors"
+
+	"github.com/twgh/xcgui/wapi"
+	"github.com/twgh/xwebview/internal/w32"
+	"golang.org/x/sys/windows"
+)
+
+// wmDispatch 是通知宿主窗口执行 Dispatch 队列中的函数的消息.
+const wmDispatch = w32.WMApp + 1
+
+// ErrWebViewDestroyed 是 WebView 已经销毁, 放入队列的函数不会再被执行.
+var ErrWebViewDestroyed = errors.New("WebView 已销毁")
+
+// IsUIThread 判断当前是否是创建 WebView 的UI线程.
+func (w *WebView) IsUIThread() bool {
+	return windows.GetCurrentThreadId() == w.uiThreadID
+}
+
+// Dispatch 把 f 放入队列, 由UI线程按放入的顺序执行, 不等待 f 执行完毕. 可以在任意线程调用.
+//
+// 在UI线程调用时 f 也不会立即执行, 而是在当前的消息处理完之后执行. WebView 销毁后 f 不会被执行.
+// f 发生的 panic 会被恢复, 交给 PanicHandler.
+func (w *WebView) Dispatch(f func()) {
+	w.dispatchMux.Lock()
+	w.dispatchQueue = append(w.dispatchQueue, f)
+	post := !w.dispatchPosted
+	w.dispatchPosted = true
+	w.dispatchMux.Unlock()
+	// 队列中已经有函数时, 之前投递的消息会把它们一起执行
+	if post {
+		wapi.PostMessageW(w.hwnd, wmDispatch, 0, 0)
+	}
+}
+
+// DispatchSync 在UI线程执行 f 并等待它返回, 返回 f 的错误. 可以在任意线程调用, 在UI线程调用时直接执行.
+//
+// f 发生 panic 时返回错误码为 ErrCodePanic 的 *Error, WebView 已经销毁时返回 ErrWebViewDestroyed.
+//
+// UI线程正在等待的协程中不能调用 DispatchSync, 否则两者互相等待. f 中等待 js 结果的调用(如 JSFunc.Call)会返回 ErrUIThreadDeadlock.
+func (w *WebView) DispatchSync(f func() error) error {
+	if w.IsUIThread() {
+		return w.runDispatched(f)
+	}
+	done := make(chan error, 1)
+	w.Dispatch(func() {
+		done <- w.runDispatched(f)
+	})
+	select {
+	case err := <-done:
+		return err
+	case <-w.ctx.Done():
+		select {
+		case err := <-done:
+			return err
+		default:
+			return ErrWebViewDestroyed
+		}
+	}
+}
+
+// runDispatched 执行 f, 把 panic 转换为错误.
+func (w *WebView) runDispatched(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = w.recoverPanic("Dispatch", r)
+		}
+	}()
+	return f()
+}
+
+// drainDispatch 执行队列中的所有函数, 在宿主窗口收到 wmDispatch 时调用.
+func (w *WebView) drainDispatch() {
+	w.dispatchMux.Lock()
+	queue := w.dispatchQueue
+	w.dispatchQueue = nil
+	w.dispatchPosted = false
+	w.dispatchMux.Unlock()
+	for _, f := range queue {
+		f := f
+		_ = w.runDispatched(func() error {
+			f()
+			return nil
+		})
+	}
+}
+
+// ui 在UI线程执行 f 并等待它返回, 当前已经是UI线程时直接执行.
+func (w *WebView) ui(f func()) {
+	_ = w.DispatchSync(func() error {
+		f()
+		return nil
+	})
+}
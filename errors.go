@@ -0,0 +1,134 @@
+package xwebview
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// 错误码, 在js中是 GoError 的 code 属性.
+const (
+	// ErrCodeInternal 是普通 error 的错误码.
+	ErrCodeInternal = "INTERNAL"
+	// ErrCodeInvalidArgs 是参数个数不匹配或 js 传来的参数解析失败.
+	ErrCodeInvalidArgs = "INVALID_ARGS"
+	// ErrCodePanic 是绑定的函数发生了 panic.
+	ErrCodePanic = "PANIC"
+	// ErrCodeBadResult 是函数的返回值无法转换为 json.
+	ErrCodeBadResult = "BAD_RESULT"
+	// ErrCodeForbidden 是调用来自不允许的来源或 iframe.
+	ErrCodeForbidden = "FORBIDDEN"
+)
+
+// CodedError 可以由自定义的错误类型实现, 绑定的函数返回这样的错误时, js 端拿到的 GoError 会带有对应的 code 和 data.
+type CodedError interface {
+	error
+	// ErrorCode 返回错误码.
+	ErrorCode() string
+	// ErrorData 返回附加数据, 会被转换为 json, 可以为 nil.
+	ErrorData() interface{}
+}
+
+// Error 是绑定的函数可以返回的结构化错误, js 端的 Promise 会被拒绝为 GoError 对象, 带有 code, message 和 data 属性.
+type Error struct {
+	// 错误码
+	Code string
+	// 错误信息
+	Message string
+	// 附加数据, 会被转换为 json
+	Data interface{}
+}
+
+// NewError 创建一个结构化错误.
+//
+// code: 错误码.
+//
+// message: 错误信息.
+//
+// data: 附加数据, 可不填.
+func NewError(code, message string, data ...interface{}) *Error {
+	e := &Error{Code: code, Message: message}
+	if len(data) > 0 {
+		e.Data = data[0]
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode 返回错误码.
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+// ErrorData 返回附加数据.
+func (e *Error) ErrorData() interface{} {
+	return e.Data
+}
+
+// errorObject 是错误在 js 端的 json 形式.
+type errorObject struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// toErrorObject 把 err 转换为返回给 js 的错误对象, 没有实现 CodedError 的错误使用 ErrCodeInternal.
+func toErrorObject(err error) errorObject {
+	var ce CodedError
+	if errors.As(err, &ce) {
+		return errorObject{Code: ce.ErrorCode(), Message: err.Error(), Data: ce.ErrorData()}
+	}
+	return errorObject{Code: ErrCodeInternal, Message: err.Error()}
+}
+
+// PanicHandler 在绑定的函数发生 panic 时被调用, panic 已被恢复, WebView 仍可继续使用.
+//
+// method: 绑定的函数名.
+//
+// value: recover 返回的值.
+//
+// stack: 发生 panic 时的调用栈.
+type PanicHandler func(method string, value interface{}, stack []byte)
+
+// PanicData 是 panic 错误的附加数据, 在 js 端为 GoError 的 data 属性.
+type PanicData struct {
+	// 发生 panic 时的调用栈
+	Stack string `json:"stack"`
+}
+
+// SetPanicHandler 设置绑定的函数发生 panic 时的回调函数, 为 nil 时使用默认的处理方式, 即输出到日志.
+//
+// 回调函数在执行绑定函数的线程或协程中被调用.
+func (w *WebView) SetPanicHandler(h PanicHandler) *WebView {
+	w.m.Lock()
+	w.panicHandler = h
+	w.m.Unlock()
+	return w
+}
+
+// recoverPanic 把 recover 的值转换为带有调用栈的错误, 并通知 PanicHandler. 必须在 recover 所在的 defer 函数中调用.
+func (w *WebView) recoverPanic(method string, r interface{}) *Error {
+	stack := debug.Stack()
+	w.m.Lock()
+	h := w.panicHandler
+	w.m.Unlock()
+
+	if h == nil {
+		log.Printf("xwebview: panic in %s: %v\n%s", method, r, stack)
+	} else {
+		// 回调函数本身发生的 panic 也不能影响 WebView
+		func() {
+			defer func() {
+				if r2 := recover(); r2 != nil {
+					log.Printf("xwebview: panic in PanicHandler: %v", r2)
+				}
+			}()
+			h(method, r, stack)
+		}()
+	}
+	return &Error{Code: ErrCodePanic, Message: fmt.Sprintf("panic: %v", r), Data: PanicData{Stack: string(stack)}}
+}
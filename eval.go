@@ -0,0 +1,239 @@
+package xwebview
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twgh/xwebview/internal/wait"
+	"golang.org/x/sys/windows"
+)
+
+// ErrPageLeft 是等待 js 的结果时页面已经离开或 WebView 已经销毁.
+var ErrPageLeft = errors.New("页面已离开")
+
+// evalResult 是 wrapEval 包装后的脚本通过 ExecuteScript 返回的值.
+type evalResult struct {
+	// Value 是表达式的值的 json.
+	Value json.RawMessage `json:"value"`
+	// Error 是表达式抛出的异常.
+	Error *JSError `json:"error"`
+	// Pending 表示表达式的值是 Promise, 它的结果通过 evalResultMessage 返回.
+	Pending bool `json:"pending"`
+}
+
+// evalResultMessage 是 js 端 awaitEval 返回的 Promise 的结果, 总是 json.
+//
+// 脚本是 Go 端执行的, 所以它不经过握手、编码方式和来源检查, 只能完成 evalScript 登记的序号.
+type evalResultMessage struct {
+	Seq    int             `json:"evalResult"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSError        `json:"error"`
+}
+
+// isEvalResult 判断消息是否是 evalResultMessage.
+func isEvalResult(message string) bool {
+	return strings.HasPrefix(message, `{"evalResult"`)
+}
+
+// resolveEval 把 js 端返回的 Promise 的结果交给等待它的 evalScript. 必须在UI线程执行.
+func (w *WebView) resolveEval(message string) {
+	var m evalResultMessage
+	if err := json.Unmarshal([]byte(message), &m); err != nil {
+		log.Printf("invalid eval result: %v", err)
+		return
+	}
+	resolveCall(&w.callsMux, w.evalCalls, m.Seq, m.Result, m.Error)
+}
+
+// wrapEval 把 js 表达式包装为返回 evalResult 的脚本. 不会在 window 上留下任何东西.
+func wrapEval(js string, seq int) string {
+	return `(function () {
+    try {
+        var r = (
+` + strings.TrimRight(strings.TrimSpace(js), ";") + `
+        );
+        if (r && typeof r.then === 'function') {
+            window._rpc.awaitEval(` + strconv.Itoa(seq) + `, r);
+            return {pending: true};
+        }
+        return {value: r === undefined ? null : r};
+    } catch (e) {
+        return {error: window._rpc.errorInfo(e)};
+    }
+})()`
+}
+
+// evalScript 通过 ExecuteScript 执行 js 表达式, 在协程中把值的 json 传给 f, 值是 Promise 时等待它完成. f 总是会被调用一次.
+// js 抛出异常时 err 为 *JSError, 超时为 ErrEvalTimeout, ctx 被取消为 ctx.Err(), 页面离开为 ErrPageLeft. 必须在UI线程执行.
+//
+// timeout: 大于0时在 ctx 的基础上增加超时.
+func (w *WebView) evalScript(ctx context.Context, js string, timeout time.Duration, f func(result json.RawMessage, err error)) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	pageCtx, _ := w.pageContext()
+	// 结果可能来自 ExecuteScript 的回调, 也可能来自 js 端的 evalResultMessage, 只会有一个
+	ch := make(chan jsFuncResult, 1)
+	w.callsMux.Lock()
+	w.jsCallID++
+	seq := w.jsCallID
+	w.evalCalls[seq] = ch
+	w.callsMux.Unlock()
+
+	w.browser.ExecuteScript(wrapEval(js, seq), func(s string, err error) {
+		var r jsFuncResult
+		var v evalResult
+		if err != nil {
+			r.err = err
+		} else if err := json.Unmarshal([]byte(s), &v); err != nil {
+			r.err = err
+		} else if v.Error != nil {
+			r.err = v.Error
+		} else if v.Pending {
+			return
+		} else {
+			r.result = v.Value
+		}
+		// 在UI线程执行, 不能阻塞. 通道中已经有结果时说明页面伪造了 evalResultMessage, 忽略
+		select {
+		case ch <- r:
+		default:
+		}
+	})
+
+	go func() {
+		defer cancel()
+		var r jsFuncResult
+		select {
+		case r = <-ch:
+		case <-ctx.Done():
+			r.err = contextError(ctx)
+		case <-pageCtx.Done():
+			r.err = ErrPageLeft
+		}
+		w.callsMux.Lock()
+		delete(w.evalCalls, seq)
+		w.callsMux.Unlock()
+		f(r.result, r.err)
+	}()
+}
+
+// timeoutError 是 ErrEvalTimeout 的类型, 让它同时满足 errors.Is(err, context.DeadlineExceeded).
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "执行超时" }
+
+func (timeoutError) Is(target error) bool { return target == context.DeadlineExceeded }
+
+func (timeoutError) Timeout() bool { return true }
+
+// contextError 返回 ctx 结束的原因, 超过截止时间时为 ErrEvalTimeout.
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrEvalTimeout
+	}
+	return ctx.Err()
+}
+
+// evalWait 执行 js 表达式, 一边处理消息一边等待值的 json. 必须在UI线程执行.
+//
+// 等待期间没有消息时线程处于阻塞状态, 不占用CPU. 等待期间处理的消息中再次调用 evalWait 时返回 ErrEvalReentrant.
+func (w *WebView) evalWait(ctx context.Context, js string, timeout time.Duration) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := w.evalGuard.Run(func() error {
+		event, err := windows.CreateEvent(nil, 0, 0, nil)
+		if err != nil {
+			return err
+		}
+		defer windows.CloseHandle(event)
+		wt := wait.New(func() { _ = windows.SetEvent(event) })
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		resultChan := make(chan jsFuncResult, 1)
+		w.evalScript(ctx, js, timeout, func(result json.RawMessage, err error) {
+			// 先唤醒再交出结果, 收到结果时 SetEvent 已经执行完
+			wt.Finish()
+			resultChan <- jsFuncResult{result: result, err: err}
+		})
+		if err := waitUI(wt, event); err != nil {
+			// 回调会触发 event, 必须等它执行完才能关闭 event, 否则句柄可能已经被别的对象重用
+			cancel()
+			<-resultChan
+			return err
+		}
+		r := <-resultChan
+		result = r.result
+		return r.err
+	})
+	return result, err
+}
+
+// EvalOption 是 EvalInto 和 EvalRaw 的选项.
+type EvalOption struct {
+	// Timeout 是超时时间, 为0时默认10秒.
+	Timeout time.Duration
+}
+
+func (o EvalOption) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return 10 * time.Second
+}
+
+// EvalRaw 执行 js 代码, 同步取回值的 json, 不经过任何转换. 必须在UI线程执行.
+//
+// js: js 表达式, 值是 Promise 时等待它完成. undefined 的 json 是 null.
+//
+// opts: 选项, 可不填.
+//
+// js 抛出异常或 Promise 被拒绝时返回 *JSError, 可以用 errors.As 取得 js 异常的 name, message 和 stack.
+// 超时返回 ErrEvalTimeout, 等待期间页面离开返回 ErrPageLeft.
+//
+// 等待期间UI线程继续处理消息, 但不能在这期间再同步执行 js, 否则返回 ErrEvalReentrant.
+func (w *WebView) EvalRaw(js string, opts ...EvalOption) (json.RawMessage, error) {
+	var o EvalOption
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return w.evalWait(context.Background(), js, o.timeout())
+}
+
+// EvalInto 执行 js 代码, 同步取回值并按 json 规则解码到 out, 整数不会像 EvalSync 那样变为 float64. 必须在UI线程执行.
+//
+// js: js 表达式, 值是 Promise 时等待它完成.
+//
+// out: 接收结果的指针, 如 *int64, *[]string, 结构体指针. 值为 null 或 undefined 时不修改 out.
+//
+// opts: 选项, 可不填.
+//
+// 错误与 EvalRaw 相同, 结果无法解码到 out 时返回 json 的错误.
+func (w *WebView) EvalInto(js string, out interface{}, opts ...EvalOption) error {
+	result, err := w.EvalRaw(js, opts...)
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result, out)
+}
+
+// decodeEval 把 js 的值的 json 转换为 interface{}, 与之前的 EvalSync 返回的值相同.
+func decodeEval(result json.RawMessage) (interface{}, error) {
+	if len(result) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	err := json.Unmarshal(result, &v)
+	return v, err
+}
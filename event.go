@@ -0,0 +1,100 @@
+package xwebview
+
+import (
+	"encoding/json"
+)
+
+// EventHandler 是 js 端事件的处理函数.
+//
+// data: js 端 window.xwebview.emit(event, data) 传入的数据的 json.
+type EventHandler func(data json.RawMessage)
+
+// eventHandler 是注册的事件处理函数, id 用于取消注册.
+type eventHandler struct {
+	id int
+	h  EventHandler
+}
+
+// pushMessage 是 Go 端通过 PostWebMessageAsJSON 发送给 js 运行时的消息, js 端根据 Type 分发.
+type pushMessage struct {
+	Type  string      `json:"type"`
+	Event string      `json:"event,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// post 用 WebView 的 Codec 编码消息后发送给 js 运行时. 必须在UI线程执行.
+func (w *WebView) post(msg interface{}) error {
+	s, err := w.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+	// json 直接作为对象发送, 其他编码作为字符串发送, 由 js 运行时解码
+	if w.codec.Name() == "json" {
+		return w.browser.PostWebMessageAsJSON(s)
+	}
+	return w.browser.PostWebMessageAsString(s)
+}
+
+// Emit 向 js 端发送事件, 页面中通过 window.xwebview.on(event, function(payload) {}) 接收. 必须在UI线程执行.
+//
+// event: 事件名.
+//
+// payload: 事件数据, 会被转换为 json.
+func (w *WebView) Emit(event string, payload interface{}) error {
+	return w.post(pushMessage{Type: "event", Event: event, Data: payload})
+}
+
+// On 注册 js 端事件的处理函数, 页面中通过 window.xwebview.emit(event, data) 发送事件. 处理函数在UI线程执行.
+//
+// event: 事件名.
+//
+// handler: 处理函数.
+//
+// 返回用于取消注册的函数.
+func (w *WebView) On(event string, handler EventHandler) (off func()) {
+	w.eventsMux.Lock()
+	w.eventID++
+	id := w.eventID
+	w.events[event] = append(w.events[event], eventHandler{id: id, h: handler})
+	w.eventsMux.Unlock()
+
+	return func() {
+		w.eventsMux.Lock()
+		defer w.eventsMux.Unlock()
+		hs := w.events[event]
+		for i := range hs {
+			if hs[i].id == id {
+				w.events[event] = append(hs[:i:i], hs[i+1:]...)
+				break
+			}
+		}
+		if len(w.events[event]) == 0 {
+			delete(w.events, event)
+		}
+	}
+}
+
+// Off 取消注册 js 端事件的所有处理函数.
+func (w *WebView) Off(event string) {
+	w.eventsMux.Lock()
+	delete(w.events, event)
+	w.eventsMux.Unlock()
+}
+
+// dispatchEvent 调用 js 端事件的处理函数.
+func (w *WebView) dispatchEvent(event string, data json.RawMessage) {
+	w.eventsMux.Lock()
+	hs := w.events[event]
+	w.eventsMux.Unlock()
+
+	for _, eh := range hs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					w.recoverPanic("event "+event, r)
+				}
+			}()
+			eh.h(data)
+		}()
+	}
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
 	_ "embed"
 	"encoding/hex"
@@ -13,6 +14,7 @@ import (
 	"github.com/twgh/xcgui/xc"
 	"github.com/twgh/xcgui/xcc"
 	"github.com/twgh/xwebview"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -55,28 +57,42 @@ func (m *MainWindow) main() {
 	m.w.Show(true)
 }
 
-// bindBasicFuncs 绑定基本函数.
-func (m *MainWindow) bindBasicFuncs() {
-	// 绑定 moveWindow
-	m.wv.Bind("moveWindow", func(x int32, y int32) {
-		// 减去阴影大小8
-		wapi.SetWindowPos(m.w.GetHWND(), 0, m.w.DpiConv(x-8), m.w.DpiConv(y-8), 0, 0, wapi.SWP_NOSIZE|wapi.SWP_NOZORDER)
-	})
+// windowAPI 是给网页调用的窗口操作函数, 通过 BindObject 绑定为 window.win.
+type windowAPI struct {
+	w *window.Window
+}
 
-	// 绑定 最小化窗口函数
-	m.wv.Bind("minimizeWindow", func() {
-		m.w.ShowWindow(xcc.SW_MINIMIZE)
-	})
+// MoveWindow 移动窗口.
+func (a *windowAPI) MoveWindow(x int32, y int32) {
+	// 减去阴影大小8
+	wapi.SetWindowPos(a.w.GetHWND(), 0, a.w.DpiConv(x-8), a.w.DpiConv(y-8), 0, 0, wapi.SWP_NOSIZE|wapi.SWP_NOZORDER)
+}
 
-	// 绑定 切换最大化窗口函数
-	m.wv.Bind("toggleMaximize", func() {
-		m.w.MaxWindow(!m.w.IsMaxWindow())
-	})
+// MinimizeWindow 最小化窗口.
+func (a *windowAPI) MinimizeWindow() {
+	a.w.ShowWindow(xcc.SW_MINIMIZE)
+}
 
-	// 绑定 关闭窗口函数
-	m.wv.Bind("closeWindow", func() {
-		m.w.CloseWindow()
-	})
+// ToggleMaximize 切换最大化窗口.
+func (a *windowAPI) ToggleMaximize() {
+	a.w.MaxWindow(!a.w.IsMaxWindow())
+}
+
+// CloseWindow 关闭窗口.
+func (a *windowAPI) CloseWindow() {
+	a.w.CloseWindow()
+}
+
+// bindBasicFuncs 绑定基本函数.
+func (m *MainWindow) bindBasicFuncs() {
+	// 绑定窗口操作函数到 window.win
+	m.wv.BindObject("win", &windowAPI{w: m.w})
+}
+
+// md5Progress 是计算MD5的进度, 在 js 端的 onProgress 中接收.
+type md5Progress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
 }
 
 // bindFuncs 绑定函数.
@@ -86,21 +102,43 @@ func (m *MainWindow) bindFuncs() {
 		return wutil.OpenFile(m.w.Handle, []string{"All Files(*.*)", "*.*"}, "")
 	})
 
-	// 绑定 calculateMD5
-	m.wv.Bind("calculateMD5", func(filePath string) string {
+	// 绑定 calculateMD5, 页面离开或窗口销毁时 ctx 会被取消, 计算随之停止. 计算过程中通过 p 报告进度
+	m.wv.Bind("calculateMD5", func(ctx context.Context, p xwebview.Progress, filePath string) string {
 		// 判断文件是否存在
 		if !xc.PathExists2(filePath) {
 			return "错误: 文件不存在"
 		}
-		// 读取文件内容
-		data, err := os.ReadFile(filePath)
+		f, err := os.Open(filePath)
+		if err != nil {
+			return "错误: " + err.Error()
+		}
+		defer f.Close()
+		info, err := f.Stat()
 		if err != nil {
 			return "错误: " + err.Error()
 		}
 
-		// 计算MD5
-		hash := md5.Sum(data)
-		md5Str := hex.EncodeToString(hash[:])
+		// 分块计算MD5
+		hash := md5.New()
+		buf := make([]byte, 1024*1024)
+		var done int64
+		for {
+			if ctx.Err() != nil {
+				return "错误: 已取消"
+			}
+			n, err := f.Read(buf)
+			hash.Write(buf[:n])
+			done += int64(n)
+			// 每块都报告, 发送到 js 端时会被节流
+			p.Report(md5Progress{Done: done, Total: info.Size()})
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "错误: " + err.Error()
+			}
+		}
+		md5Str := hex.EncodeToString(hash.Sum(nil))
 
 		// 返回结果（包含文件名和MD5）
 		return "文件: " + filePath + "\nMD5: " + md5Str
@@ -0,0 +1,185 @@
+package xwebview
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ExecMode 是绑定的函数的执行方式.
+type ExecMode int
+
+const (
+	// ExecDefault 是默认的执行方式: 第一个参数是 context.Context、参数中有 JSFunc 或返回通道的函数在协程池中执行, 其他函数在UI线程执行.
+	//
+	// 参数中有 JSFunc 的函数需要等待 js 的结果, 在UI线程执行时 JSFunc.Call 会返回 ErrUIThreadDeadlock.
+	ExecDefault ExecMode = iota
+	// ExecUIThread 在UI线程执行, 可以直接操作炫彩的界面, 但函数执行期间窗口无法响应.
+	ExecUIThread
+	// ExecGoroutine 在协程池中并发执行, 不会阻塞UI线程, 操作界面需要使用 WebView.Dispatch 或 xc.XC_CallUT.
+	ExecGoroutine
+	// ExecSerial 在协程池中按调用顺序逐个执行, 同一个函数的多次调用不会并发.
+	ExecSerial
+)
+
+// resolve 返回 ExecDefault 对应的实际执行方式.
+func (m ExecMode) resolve(b *binding) ExecMode {
+	if m != ExecDefault {
+		return m
+	}
+	if b.withContext || b.stream || b.withJSFunc {
+		return ExecGoroutine
+	}
+	return ExecUIThread
+}
+
+// ErrCodeBusy 是协程池的等待队列已满, 调用没有执行.
+const ErrCodeBusy = "BUSY"
+
+// poolTask 是协程池中等待执行的调用. WebView 销毁时还没有执行的调用不再执行 run, 而是以 ErrWebViewDestroyed 调用 cancel,
+// 让它结束调用并释放占用的资源(如限流的额度).
+type poolTask struct {
+	run    func()
+	cancel func(err error)
+}
+
+// workerPool 是固定数量的常驻协程, 从有界的队列中取出函数执行.
+type workerPool struct {
+	queue chan poolTask
+	size  int // 队列的容量, 也是每个 serialQueue 的容量
+
+	mu     sync.Mutex
+	closed bool // done 已经关闭, 不再接受新的调用
+}
+
+// newWorkerPool 创建协程池并启动 n 个协程, 它们在 done 关闭时退出, 队列中剩余的调用被取消.
+//
+// n: 协程数量, 小于等于0时使用 CPU 核心数的4倍.
+//
+// queue: 等待执行的函数的最大数量, 小于等于0时为 n 的16倍.
+func newWorkerPool(n, queue int, done <-chan struct{}) *workerPool {
+	if n <= 0 {
+		n = runtime.NumCPU() * 4
+	}
+	if queue <= 0 {
+		queue = n * 16
+	}
+	p := &workerPool{queue: make(chan poolTask, queue), size: queue}
+	for i := 0; i < n; i++ {
+		go p.work(done)
+	}
+	go func() {
+		<-done
+		p.close()
+	}()
+	return p
+}
+
+func (p *workerPool) work(done <-chan struct{}) {
+	for {
+		select {
+		case t := <-p.queue:
+			t.run()
+		case <-done:
+			return
+		}
+	}
+}
+
+// close 停止接受新的调用, 并取消队列中剩余的调用.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	// 关闭后不会再有调用放入队列, 取完即可
+	for {
+		select {
+		case t := <-p.queue:
+			t.cancel(ErrWebViewDestroyed)
+		default:
+			return
+		}
+	}
+}
+
+func (p *workerPool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// submit 把 t 放入队列, 由空闲的协程执行, 不会阻塞调用者. 队列已满时返回错误码为 ErrCodeBusy 的错误,
+// 协程池已经关闭时返回 ErrWebViewDestroyed, 这时 t 不会执行.
+func (p *workerPool) submit(t poolTask) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrWebViewDestroyed
+	}
+	select {
+	case p.queue <- t:
+		return nil
+	default:
+		return NewError(ErrCodeBusy, "等待执行的调用过多")
+	}
+}
+
+// serialQueue 按顺序逐个执行同一个绑定的调用.
+type serialQueue struct {
+	mu      sync.Mutex
+	tasks   []poolTask
+	running bool
+}
+
+// push 把 t 加入队列, 队列空闲时在协程池中开始执行. 队列已满或协程池的队列已满时返回 ErrCodeBusy 的错误.
+func (q *serialQueue) push(p *workerPool, t poolTask) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tasks) >= p.size {
+		return NewError(ErrCodeBusy, "等待执行的调用过多")
+	}
+	if !q.running {
+		// submit 不会阻塞, 可以在持有锁时调用
+		err := p.submit(poolTask{
+			run:    func() { q.drain(p) },
+			cancel: q.cancel,
+		})
+		if err != nil {
+			return err
+		}
+		q.running = true
+	}
+	q.tasks = append(q.tasks, t)
+	return nil
+}
+
+// drain 执行队列中的函数, 直到队列为空. 协程池关闭后取消剩余的调用.
+func (q *serialQueue) drain(p *workerPool) {
+	for {
+		if p.isClosed() {
+			q.cancel(ErrWebViewDestroyed)
+			return
+		}
+		q.mu.Lock()
+		if len(q.tasks) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		t := q.tasks[0]
+		q.tasks = q.tasks[1:]
+		q.mu.Unlock()
+		t.run()
+	}
+}
+
+// cancel 取消队列中所有的调用.
+func (q *serialQueue) cancel(err error) {
+	q.mu.Lock()
+	tasks := q.tasks
+	q.tasks = nil
+	q.running = false
+	q.mu.Unlock()
+	for _, t := range tasks {
+		t.cancel(err)
+	}
+}
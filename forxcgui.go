@@ -7,7 +7,9 @@ import (
 	"github.com/twgh/xcgui/xc"
 	"github.com/twgh/xcgui/xcc"
 	"github.com/twgh/xwebview/pkg/edge"
+	"golang.org/x/sys/windows"
 	"log"
+	"reflect"
 	"strconv"
 	"syscall"
 	"unsafe"
@@ -42,6 +44,19 @@ type XcWebViewOption struct {
 
 	// AutoFocus 将在窗口获得焦点时尝试保持 webView 的焦点。
 	AutoFocus bool
+
+	// MaxWorkers 是协程池中最多同时执行的绑定函数数量, 所有 ExecGoroutine 和 ExecSerial 的函数共用, 为0时使用 CPU 核心数的4倍.
+	MaxWorkers int
+	// MaxQueue 是协程池中等待执行的调用的最大数量, 每个 ExecSerial 的函数也分别受它限制, 为0时为 MaxWorkers 的16倍.
+	// 超出时调用不会执行, js 端的 Promise 被拒绝为错误码为 ErrCodeBusy 的 GoError.
+	MaxQueue int
+	// Codec 是 Go 端与 js 运行时之间的消息的编码方式, 为 nil 时使用 CodecJSON.
+	Codec Codec
+	// AllowedOrigins 是允许调用所有绑定的函数和发送事件的页面来源, 为空时不限制, 规则见 BindOption.AllowedOrigins.
+	// 导航到第三方网站时, 建议设置它, 否则任何页面都可以调用绑定的函数.
+	AllowedOrigins []string
+	// RateLimit 限制所有绑定的函数的调用频率和并发数的总和, 默认不限制, 见 RateLimit.
+	RateLimit RateLimit
 }
 
 // New 创建 webview 窗口到炫彩窗口或元素, 失败返回nil.
@@ -54,11 +69,25 @@ func New(hParent int, opt XcWebViewOption) *WebView {
 		return nil
 	}
 	w := &WebView{}
-	w.bindings = map[string]interface{}{}
+	w.bindings = map[string]*binding{}
+	w.events = map[string][]eventHandler{}
 	w.autofocus = opt.AutoFocus
+	w.uiThreadID = windows.GetCurrentThreadId()
+	w.allowedOrigins = opt.AllowedOrigins
+	w.limiter = newLimiter("webview", opt.RateLimit)
+	w.metrics = newMetrics()
+	w.codec = opt.Codec
+	if w.codec == nil {
+		w.codec = CodecJSON
+	}
+	w.initContext()
+	w.pool = newWorkerPool(opt.MaxWorkers, opt.MaxQueue, w.ctx.Done())
 
 	chromium := edge.NewChromium()
-	chromium.MessageCallback = w.msgcb_xcgui
+	chromium.MessageWithArgsCallback = w.msgcb_xcgui
+	chromium.NavigationStartingCallback = w.onNavigationStarting
+	chromium.NavigationCompletedCallback = w.onNavigationCompleted
+	chromium.ContentLoadingCallback = w.onContentLoading
 	chromium.DataPath = opt.DataPath
 	chromium.SetPermission(edge.CoreWebView2PermissionKindClipboardRead, edge.CoreWebView2PermissionStateAllow)
 
@@ -66,6 +95,9 @@ func New(hParent int, opt XcWebViewOption) *WebView {
 	if !w.createWithOptionsByXcgui(hParent, opt) {
 		return nil
 	}
+	w.Init(runtimeJS)
+	w.Eval(runtimeJS)
+	w.bindBuiltin(bindingsMethod, w.Bindings)
 
 	settings, err := chromium.GetSettings()
 	if err != nil {
@@ -250,25 +282,173 @@ func onEleShow(hEle int, bShow bool, pbHandled *bool) int {
 	return 0
 }
 
-func (w *WebView) msgcb_xcgui(msg string) {
+func (w *WebView) msgcb_xcgui(message string, args *edge.ICoreWebView2WebMessageReceivedEventArgs) {
+	// 这里是 WebView2 的 COM 回调, panic 不能继续向上传递, 否则整个程序会崩溃
+	defer func() {
+		if r := recover(); r != nil {
+			w.recoverPanic("msgcb_xcgui", r)
+		}
+	}()
+
+	if isEvalResult(message) {
+		w.resolveEval(message)
+		return
+	}
+	msg := []byte(message)
+	if !isHello(message) {
+		var err error
+		if msg, err = w.codec.Decode(message); err != nil {
+			log.Printf("invalid RPC message: %v", err)
+			return
+		}
+	}
+	caller := w.newCaller(args)
+	if isBatch(msg) {
+		w.handleJSONRPC(msg, caller)
+		return
+	}
+	// JSON-RPC 的 id 可以是字符串, params 可以是对象, 类型不匹配时 Unmarshal 仍会填充 JSONRPC
 	d := rpcMessage{}
-	if err := json.Unmarshal([]byte(msg), &d); err != nil {
+	err := json.Unmarshal(msg, &d)
+	if d.JSONRPC != "" {
+		w.handleJSONRPC(msg, caller)
+		return
+	}
+	if err != nil {
 		log.Printf("invalid RPC message: %v", err)
 		return
 	}
+	if d.Hello != nil {
+		w.handshake(*d.Hello, caller)
+		return
+	}
+	if d.Cancel != 0 {
+		w.cancelCall(d.Cancel)
+		return
+	}
+	if d.Stream != 0 {
+		w.addStreamCredit(d.Stream, d.Credit)
+		return
+	}
+	if d.Callback != 0 {
+		// 返回值和事件数据中的 js 函数不会变为 JSFunc, 没有人能释放它们
+		w.releaseJSFuncs(newJSFuncRefs(d.Result))
+		if err := w.checkCaller(caller, nil); err != nil {
+			log.Printf("callback %d dropped: %v", d.Callback, err)
+			return
+		}
+		w.resolveJSFunc(d.Callback, d.Result, d.Error)
+		return
+	}
+	if d.Event != "" {
+		w.releaseJSFuncs(newJSFuncRefs(d.Data))
+		if err := w.checkCaller(caller, nil); err != nil {
+			log.Printf("event %q dropped: %v", d.Event, err)
+			return
+		}
+		w.dispatchEvent(d.Event, d.Data)
+		return
+	}
 
-	id := strconv.Itoa(d.ID)
-	if res, err := w.callbinding(d); err != nil {
-		xc.XC_CallUT(func() {
-			w.Eval("window._rpc[" + id + "].reject(" + jsString(err.Error()) + "); window._rpc[" + id + "] = undefined")
-		})
-	} else if b, err := json.Marshal(res); err != nil {
-		xc.XC_CallUT(func() {
-			w.Eval("window._rpc[" + id + "].reject(" + jsString(err.Error()) + "); window._rpc[" + id + "] = undefined")
+	// 不允许的来源不能通过返回值判断函数是否存在
+	d.funcs = newJSFuncRefs(d.Params...)
+	b, ok := w.getBinding(d.Method)
+	if err := w.checkCaller(caller, b); err != nil {
+		w.releaseJSFuncs(d.funcs)
+		w.reply(d.ID, nil, err)
+		return
+	}
+	if !ok {
+		w.releaseJSFuncs(d.funcs)
+		w.reply(d.ID, nil, nil)
+		return
+	}
+	release, err := w.admit(b)
+	if err != nil {
+		w.releaseJSFuncs(d.funcs)
+		w.reply(d.ID, nil, err)
+		return
+	}
+	d.caller = caller
+	ctx, page, finish := w.startCall(d.ID)
+	done := func() {
+		finish()
+		release()
+	}
+	ctx = withCaller(ctx, caller)
+	d.progress = w.newProgress(page, d.ID)
+	run := func() {
+		res, err := w.callbinding(ctx, b, d)
+		w.releaseJSFuncs(d.funcs)
+		d.progress.close()
+		// 返回了通道, 调用在流结束后才算完成. 流不占用协程池
+		if b.stream && err == nil {
+			go func() {
+				defer done()
+				w.runStream(ctx, page, d.ID, reflect.ValueOf(res))
+			}()
+			return
+		}
+		done()
+		w.ui(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					w.recoverPanic(d.Method, r)
+				}
+			}()
+			// 页面已经离开, 新页面中相同 id 的调用不是这一个
+			if w.isCurrentPage(page) {
+				n := w.reply(d.ID, res, err)
+				if !b.builtin {
+					w.metrics.response(d.Method, n)
+				}
+			}
 		})
-	} else {
-		xc.XC_CallUT(func() {
-			w.Eval("window._rpc[" + id + "].resolve(" + string(b) + "); window._rpc[" + id + "] = undefined")
+	}
+
+	// 没有执行时结束调用, 在UI线程拒绝 js 端的 Promise
+	cancel := func(err error) {
+		w.releaseJSFuncs(d.funcs)
+		d.progress.close()
+		done()
+		w.ui(func() {
+			if w.isCurrentPage(page) {
+				w.reply(d.ID, nil, err)
+			}
 		})
 	}
+	if err := w.execute(b, run, cancel); err != nil {
+		cancel(err)
+	}
+}
+
+// execute 按绑定的执行方式执行 run. 协程池的队列已满时返回 ErrCodeBusy 的错误, run 不会执行.
+// 放入协程池的 run 在 WebView 销毁前还没有执行时, 以 ErrWebViewDestroyed 调用 cancel 代替 run.
+func (w *WebView) execute(b *binding, run func(), cancel func(err error)) error {
+	t := poolTask{run: run, cancel: cancel}
+	switch b.exec {
+	case ExecGoroutine:
+		return w.pool.submit(t)
+	case ExecSerial:
+		return b.queue.push(w.pool, t)
+	default:
+		run()
+		return nil
+	}
+}
+
+// reply 把调用结果返回给 js 端的 Promise, 返回编码后的结果或错误的字节数. 必须在UI线程执行.
+func (w *WebView) reply(id int, res interface{}, err error) int {
+	seq := strconv.Itoa(id)
+	if err == nil {
+		v, e := w.jsValue(res)
+		if e == nil {
+			w.Eval("window._rpc.resolve(" + seq + ", " + v + ");")
+			return len(v)
+		}
+		err = NewError(ErrCodeBadResult, e.Error())
+	}
+	v := w.jsError(err)
+	w.Eval("window._rpc.reject(" + seq + ", " + v + ");")
+	return len(v)
 }
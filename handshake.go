@@ -0,0 +1,78 @@
+package xwebview
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProtocolVersion 是 Go 端与 js 运行时之间的桥接协议的版本, 消息格式不兼容地改变时递增.
+//
+// 页面加载时 js 运行时发送它支持的版本和编码方式, Go 端选择双方都支持的最高版本, 并确认 js 端支持 XcWebViewOption.Codec,
+// 之后 js 端的 window.xwebview.ready 被解决, 在此之前的调用会排队. 不兼容时 ready 和排队的调用被拒绝, 错误码为 ErrCodeProtocol.
+const ProtocolVersion = 1
+
+// minProtocolVersion 是 Go 端仍然支持的最低协议版本.
+const minProtocolVersion = 1
+
+// ErrCodeProtocol 是页面的 js 运行时没有完成握手, 或者与 Go 端的协议版本、编码方式不兼容.
+const ErrCodeProtocol = "PROTOCOL"
+
+// helloMessage 是 js 运行时在页面加载时发送的握手消息, 总是 json.
+type helloMessage struct {
+	Versions []int    `json:"versions"`
+	Codecs   []string `json:"codecs"`
+}
+
+// welcomeMessage 是 Go 端对握手消息的回复, 总是 json.
+type welcomeMessage struct {
+	Type    string       `json:"type"`
+	Version int          `json:"version,omitempty"`
+	Codec   string       `json:"codec,omitempty"`
+	Error   *errorObject `json:"error,omitempty"`
+}
+
+// isHello 判断消息是否是握手消息. 握手时还没有协商编码方式, 所以它不经过 Codec 解码.
+func isHello(message string) bool {
+	return strings.HasPrefix(message, `{"hello"`)
+}
+
+// handshake 处理握手消息, 回复协商的协议版本和编码方式. 只处理来自顶层文档的握手. 必须在UI线程执行.
+func (w *WebView) handshake(h helloMessage, caller *Caller) {
+	if caller != nil && !caller.IsMainFrame {
+		return
+	}
+	version := 0
+	for _, v := range h.Versions {
+		if v >= minProtocolVersion && v <= ProtocolVersion && v > version {
+			version = v
+		}
+	}
+	codec := false
+	for _, c := range h.Codecs {
+		if c == w.codec.Name() {
+			codec = true
+			break
+		}
+	}
+
+	resp := welcomeMessage{Type: "welcome"}
+	switch {
+	case version == 0:
+		resp.Error = &errorObject{Code: ErrCodeProtocol, Message: fmt.Sprintf("不支持的协议版本 %v, Go 端支持 %d-%d", h.Versions, minProtocolVersion, ProtocolVersion)}
+	case !codec:
+		resp.Error = &errorObject{Code: ErrCodeProtocol, Message: "js 运行时不支持编码方式 " + w.codec.Name()}
+	default:
+		resp.Version, resp.Codec = version, w.codec.Name()
+		w.callsMux.Lock()
+		w.protocol = version
+		w.callsMux.Unlock()
+	}
+	_ = w.browser.PostWebMessageAsJSON(jsString(resp))
+}
+
+// protocolReady 判断当前页面是否已经完成握手.
+func (w *WebView) protocolReady() bool {
+	w.callsMux.Lock()
+	defer w.callsMux.Unlock()
+	return w.protocol != 0
+}
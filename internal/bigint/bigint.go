@@ -0,0 +1,116 @@
+// Package bigint 实现 xwebview 的 json-bigint 编码: 超出 js 安全整数范围的整数在 json 中写为 {"$bigint":"N"},
+// js 运行时把它转换为 BigInt.
+package bigint
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// maxSafeInteger 是 js 中的 Number.MAX_SAFE_INTEGER.
+const maxSafeInteger = 1<<53 - 1
+
+// Wrap 把 json 中超出 js 安全整数范围的整数替换为 {"$bigint":"N"}, 其他内容不变.
+func Wrap(data []byte) ([]byte, error) {
+	return rewrite(data, true)
+}
+
+// Unwrap 把 js 端发来的 json 中的 {"$bigint":"N"} 替换为整数 N, 其他内容不变.
+func Unwrap(data []byte) ([]byte, error) {
+	return rewrite(data, false)
+}
+
+func rewrite(data []byte, wrap bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	if err := rewriteValue(dec, &out, wrap); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func rewriteValue(dec *json.Decoder, out *bytes.Buffer, wrap bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		start := out.Len()
+		out.WriteByte(byte(t))
+		n := 0
+		key := ""
+		valueStart := 0
+		for ; dec.More(); n++ {
+			if n > 0 {
+				out.WriteByte(',')
+			}
+			if t == '{' {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key = k.(string)
+				b, _ := json.Marshal(key)
+				out.Write(b)
+				out.WriteByte(':')
+			}
+			valueStart = out.Len()
+			if err := rewriteValue(dec, out, wrap); err != nil {
+				return err
+			}
+		}
+		end, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		out.WriteByte(byte(end.(json.Delim)))
+		if !wrap && t == '{' && n == 1 && key == "$bigint" {
+			var s string
+			if json.Unmarshal(out.Bytes()[valueStart:out.Len()-1], &s) == nil && isInteger(s) {
+				out.Truncate(start)
+				out.WriteString(s)
+			}
+		}
+	case json.Number:
+		if wrap && isBigInt(string(t)) {
+			out.WriteString(`{"$bigint":"` + string(t) + `"}`)
+		} else {
+			out.WriteString(string(t))
+		}
+	case string:
+		b, _ := json.Marshal(t)
+		out.Write(b)
+	case bool:
+		out.WriteString(strconv.FormatBool(t))
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+// isInteger 判断 s 是否是十进制整数.
+func isInteger(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isBigInt 判断 json 数字 s 是否是超出 js 安全整数范围的整数.
+func isBigInt(s string) bool {
+	if !isInteger(s) {
+		return false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return err != nil || i > maxSafeInteger || i < -maxSafeInteger
+}
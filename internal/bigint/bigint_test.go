@@ -0,0 +1,93 @@
+package bigint
+
+import "testing"
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"safe", `9007199254740991`, `9007199254740991`},
+		{"above 2^53", `9007199254740993`, `{"$bigint":"9007199254740993"}`},
+		{"max int64", `9223372036854775807`, `{"$bigint":"9223372036854775807"}`},
+		{"max uint64", `18446744073709551615`, `{"$bigint":"18446744073709551615"}`},
+		{"negative safe", `-9007199254740991`, `-9007199254740991`},
+		{"negative", `-9007199254740993`, `{"$bigint":"-9007199254740993"}`},
+		{"min int64", `-9223372036854775808`, `{"$bigint":"-9223372036854775808"}`},
+		{"exponent", `1e20`, `1e20`},
+		{"negative exponent", `-1E+30`, `-1E+30`},
+		{"fraction", `9007199254740993.5`, `9007199254740993.5`},
+		{"zero", `0`, `0`},
+		{"nested", `{"a":[1,9007199254740993,{"b":-9223372036854775808}],"c":"9007199254740993"}`,
+			`{"a":[1,{"$bigint":"9007199254740993"},{"b":{"$bigint":"-9223372036854775808"}}],"c":"9007199254740993"}`},
+		{"literals", `[true,false,null,"x\"y"]`, `[true,false,null,"x\"y"]`},
+		{"empty", `{"a":[],"b":{}}`, `{"a":[],"b":{}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Wrap([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Wrap(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bigint", `{"$bigint":"9007199254740993"}`, `9007199254740993`},
+		{"negative", `{"$bigint":"-9223372036854775808"}`, `-9223372036854775808`},
+		{"nested", `{"a":[{"$bigint":"18446744073709551615"}],"b":1}`, `{"a":[18446744073709551615],"b":1}`},
+		{"not integer", `{"$bigint":"1e20"}`, `{"$bigint":"1e20"}`},
+		{"not string", `{"$bigint":12}`, `{"$bigint":12}`},
+		{"bare minus", `{"$bigint":"-"}`, `{"$bigint":"-"}`},
+		{"extra key", `{"$bigint":"1","x":2}`, `{"$bigint":"1","x":2}`},
+		{"other key", `{"bigint":"1"}`, `{"bigint":"1"}`},
+		{"plain", `[1,"a",null]`, `[1,"a",null]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unwrap([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Unwrap(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := `{"id":12345678901234567890,"n":-9007199254740993,"s":[1,2.5,"3"]}`
+	wrapped, err := Wrap([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unwrap(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != in {
+		t.Fatalf("round trip = %s, want %s", got, in)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, in := range []string{``, `{`, `[1,`, `{"a"}`, `{"a":}`, `]`} {
+		if _, err := Wrap([]byte(in)); err == nil {
+			t.Errorf("Wrap(%q) returned no error", in)
+		}
+		if _, err := Unwrap([]byte(in)); err == nil {
+			t.Errorf("Unwrap(%q) returned no error", in)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package origin 实现页面来源的计算和 AllowedOrigins 模式的匹配, 是绑定的函数的访问控制边界.
+package origin
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Null 是没有来源的页面的来源, 如 about:blank, data: URI 和 file://.
+const Null = "null"
+
+// defaultPorts 是协议的默认端口, 来源和模式中的默认端口被去掉, https://a.com:443 与 https://a.com 相同.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// Of 返回 uri 的来源, 格式为 scheme://host[:port], 全部为小写, 去掉默认端口. 没有主机名时为 Null.
+func Of(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return Null
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme + "://" + trimDefaultPort(scheme, strings.ToLower(u.Host))
+}
+
+// MatchAny 判断 origin 是否匹配 patterns 中的任意一个, patterns 为空时总是匹配.
+func MatchAny(patterns []string, origin string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if Match(p, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 判断由 Of 得到的 origin 是否匹配 pattern, 规则见 xwebview.BindOption.AllowedOrigins.
+//
+// "*.example.com" 只匹配子域名, 不匹配 example.com 本身. 模式中没有端口时匹配任意端口.
+func Match(pattern, origin string) bool {
+	pattern = strings.ToLower(strings.TrimRight(pattern, "/"))
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+	i := strings.Index(origin, "://")
+	if origin == Null || i < 0 {
+		return false
+	}
+	scheme, host := origin[:i], origin[i+3:]
+	if j := strings.Index(pattern, "://"); j >= 0 {
+		if pattern[:j] != scheme {
+			return false
+		}
+		pattern = trimDefaultPort(scheme, pattern[j+3:])
+	}
+	if !hasPort(pattern) {
+		host = hostname(host)
+	} else if !hasPort(host) {
+		// 来源中的默认端口已经被去掉, 与模式中的默认端口比较时补上
+		if port, ok := defaultPorts[scheme]; ok {
+			host += ":" + port
+		}
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == host
+}
+
+// hasPort 判断 host[:port] 中是否有端口, 支持 [::1]:8080 形式的 IPv6 地址.
+func hasPort(hostport string) bool {
+	return strings.LastIndex(hostport, ":") > strings.LastIndex(hostport, "]")
+}
+
+// hostname 去掉 host[:port] 中的端口.
+func hostname(hostport string) string {
+	if !hasPort(hostport) {
+		return hostport
+	}
+	return hostport[:strings.LastIndex(hostport, ":")]
+}
+
+// trimDefaultPort 去掉 host[:port] 中 scheme 的默认端口.
+func trimDefaultPort(scheme, hostport string) string {
+	if port, ok := defaultPorts[scheme]; ok && hasPort(hostport) && strings.HasSuffix(hostport, ":"+port) {
+		return hostname(hostport)
+	}
+	return hostport
+}
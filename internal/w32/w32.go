@@ -23,6 +23,8 @@ var (
 	User32SetWindowTextW   = user32.NewProc("SetWindowTextW")
 	User32AdjustWindowRect = user32.NewProc("AdjustWindowRect")
 	User32SetWindowPos     = user32.NewProc("SetWindowPos")
+
+	User32MsgWaitForMultipleObjectsEx = user32.NewProc("MsgWaitForMultipleObjectsEx")
 )
 
 const (
@@ -42,6 +44,19 @@ const (
 	WSOverlappedWindow = (WSOverlapped | WSCaption | WSSysMenu | WSThickFrame | WSMinimizeBox | WSMaximizeBox)
 )
 
+const (
+	WMQuit = 0x0012
+	WMApp  = 0x8000
+)
+
+const (
+	QSAllInput         = 0x04FF
+	MWMOInputAvailable = 0x0004
+	Infinite           = 0xFFFFFFFF
+	WaitObject0        = 0x00000000
+	WaitFailed         = 0xFFFFFFFF
+)
+
 const (
 	WAInactive    = 0
 	WAActive      = 1
@@ -103,3 +118,16 @@ func SHCreateMemStream(data []byte) (uintptr, error) {
 
 	return ret, nil
 }
+
+// MsgWaitForMultipleObjectsEx 等待 handles 中的对象被触发或消息队列中有 wakeMask 指定的消息, 返回值见 WaitObject0.
+func MsgWaitForMultipleObjectsEx(handles []windows.Handle, milliseconds uint32, wakeMask uint32, flags uint32) (uint32, error) {
+	var p uintptr
+	if len(handles) > 0 {
+		p = uintptr(unsafe.Pointer(&handles[0]))
+	}
+	ret, _, err := User32MsgWaitForMultipleObjectsEx.Call(uintptr(len(handles)), p, uintptr(milliseconds), uintptr(wakeMask), uintptr(flags))
+	if uint32(ret) == WaitFailed {
+		return WaitFailed, err
+	}
+	return uint32(ret), nil
+}
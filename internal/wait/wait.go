@@ -0,0 +1,72 @@
+// Package wait 实现UI线程一边处理消息一边等待协程中的结果的逻辑, 不依赖 Windows, 系统调用由调用者传入.
+package wait
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrReentrant 是在等待期间处理的消息中又开始了一次同步等待.
+var ErrReentrant = errors.New("不能在等待 js 的结果时再次同步执行 js")
+
+// Waiter 让UI线程一边处理消息一边等待协程中的结果.
+//
+// 协程调用 Finish 通知结果已经准备好, Wait 在UI线程循环: 没有结果时用 block 等待新消息或 Finish 的唤醒, 有消息时用 pump 处理.
+// 唤醒必须是可以累积的(如自动重置的事件), 这样 Finish 在 block 之前发生也不会丢失.
+type Waiter struct {
+	done chan struct{}
+	once sync.Once
+	wake func() // 唤醒 block, 可以在任意协程调用
+}
+
+// New 创建 Waiter, wake 用于唤醒 Wait 中的 block.
+func New(wake func()) *Waiter {
+	return &Waiter{done: make(chan struct{}), wake: wake}
+}
+
+// Finish 通知结果已经准备好, 可以在任意协程调用多次, 只有第一次会唤醒.
+func (wt *Waiter) Finish() {
+	wt.once.Do(func() {
+		close(wt.done)
+		wt.wake()
+	})
+}
+
+// Wait 等待 Finish 被调用.
+//
+// block: 阻塞到有新消息或被 wake 唤醒, 有新消息时返回 true. 返回错误时停止等待并返回这个错误.
+//
+// pump: 处理队列中的消息, 返回错误时停止等待并返回这个错误.
+func (wt *Waiter) Wait(block func() (bool, error), pump func() error) error {
+	for {
+		select {
+		case <-wt.done:
+			return nil
+		default:
+		}
+		hasMessage, err := block()
+		if err != nil {
+			return err
+		}
+		if hasMessage {
+			if err := pump(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Guard 防止同步等待重入. 零值可用, 只能在同一个线程使用.
+type Guard struct {
+	active bool
+}
+
+// Run 执行 f, f 执行期间再次调用 Run 时返回 ErrReentrant 而不执行.
+func (g *Guard) Run(f func() error) error {
+	if g.active {
+		return ErrReentrant
+	}
+	g.active = true
+	defer func() { g.active = false }()
+	return f()
+}
@@ -0,0 +1,190 @@
+package wait
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// event 模拟自动重置的事件, 多次触发只保留一次.
+type event chan struct{}
+
+func newEvent() event { return make(event, 1) }
+
+func (e event) set() {
+	select {
+	case e <- struct{}{}:
+	default:
+	}
+}
+
+// block 等待事件或消息.
+func (e event) block(msgs <-chan struct{}) func() (bool, error) {
+	return func() (bool, error) {
+		select {
+		case <-e:
+			return false, nil
+		case <-msgs:
+			return true, nil
+		}
+	}
+}
+
+func TestFinishBeforeBlock(t *testing.T) {
+	e := newEvent()
+	wt := New(e.set)
+	wt.Finish()
+	blocks := 0
+	err := wt.Wait(func() (bool, error) {
+		blocks++
+		return false, nil
+	}, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks != 0 {
+		t.Fatalf("block called %d times after Finish", blocks)
+	}
+}
+
+func TestFinishBetweenCheckAndBlock(t *testing.T) {
+	e := newEvent()
+	wt := New(e.set)
+	first := true
+	block := e.block(nil)
+	err := wt.Wait(func() (bool, error) {
+		// Finish 在检查之后、阻塞之前发生, 唤醒必须保留到 block
+		if first {
+			first = false
+			wt.Finish()
+		}
+		return block()
+	}, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFinishConcurrent(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		e := newEvent()
+		var wakes int
+		var mu sync.Mutex
+		wt := New(func() {
+			mu.Lock()
+			wakes++
+			mu.Unlock()
+			e.set()
+		})
+		// 多个协程同时 Finish, 只唤醒一次
+		var wg sync.WaitGroup
+		for j := 0; j < 16; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wt.Finish()
+			}()
+		}
+		msgs := make(chan struct{}, 4)
+		for j := 0; j < 4; j++ {
+			msgs <- struct{}{}
+		}
+		pumped := 0
+		err := wt.Wait(e.block(msgs), func() error {
+			pumped++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if wakes != 1 {
+			t.Fatalf("wake called %d times", wakes)
+		}
+	}
+}
+
+func TestPumpError(t *testing.T) {
+	e := newEvent()
+	wt := New(e.set)
+	quit := errors.New("quit")
+	err := wt.Wait(func() (bool, error) { return true, nil }, func() error { return quit })
+	if err != quit {
+		t.Fatalf("err = %v, want %v", err, quit)
+	}
+}
+
+func TestBlockError(t *testing.T) {
+	e := newEvent()
+	wt := New(e.set)
+	failed := errors.New("wait failed")
+	pumped := false
+	err := wt.Wait(func() (bool, error) { return true, failed }, func() error {
+		pumped = true
+		return nil
+	})
+	if err != failed {
+		t.Fatalf("err = %v, want %v", err, failed)
+	}
+	if pumped {
+		t.Fatal("pump called after block error")
+	}
+}
+
+func TestWaitForGoroutine(t *testing.T) {
+	e := newEvent()
+	wt := New(e.set)
+	// 结果在 Finish 之前写入, Wait 返回后读取它不能有数据竞争
+	var result int
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		result = 42
+		wt.Finish()
+	}()
+	if err := wt.Wait(e.block(nil), func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-wt.done:
+	default:
+		t.Fatal("Wait returned before Finish")
+	}
+	if result != 42 {
+		t.Fatalf("result = %d", result)
+	}
+}
+
+func TestGuardReentrant(t *testing.T) {
+	var g Guard
+	var inner error
+	err := g.Run(func() error {
+		inner = g.Run(func() error {
+			t.Fatal("nested Run executed f")
+			return nil
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner != ErrReentrant {
+		t.Fatalf("nested Run = %v, want ErrReentrant", inner)
+	}
+	// 结束后可以再次执行, f 的错误原样返回
+	failed := errors.New("failed")
+	if err := g.Run(func() error { return failed }); err != failed {
+		t.Fatalf("err = %v, want %v", err, failed)
+	}
+}
+
+func TestGuardPanicReleases(t *testing.T) {
+	var g Guard
+	func() {
+		defer func() { _ = recover() }()
+		_ = g.Run(func() error { panic("boom") })
+	}()
+	if err := g.Run(func() error { return nil }); err != nil {
+		t.Fatalf("Run after panic = %v", err)
+	}
+}
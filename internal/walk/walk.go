@@ -0,0 +1,80 @@
+// Package walk 实现 WebView.BindObject 和 tsgen.Generator.AddObject 共用的对象遍历规则, 让生成的 TypeScript 声明与绑定的函数一致.
+package walk
+
+import (
+	"errors"
+	"reflect"
+	"unicode"
+)
+
+// MethodExcluder 可以由传给 BindObject 的对象实现, 用来排除不需要绑定的方法.
+type MethodExcluder interface {
+	// ExcludeMethods 返回不需要绑定的方法名, 使用 Go 中的方法名, 如 "Close".
+	ExcludeMethods() []string
+}
+
+// Object 对 obj 的每个要绑定的方法调用 f, name 是完整的名称 namespace.method, 方法名由 MethodName 转换.
+//
+// obj 实现了 MethodExcluder 时, 返回的方法和 ExcludeMethods 本身被排除.
+// obj 是结构体或结构体指针时, 带有 `xwebview:"name"` 标签的导出字段作为嵌套的命名空间 namespace.name 递归处理, 为 nil 的字段被忽略.
+func Object(namespace string, obj interface{}, f func(name string, method reflect.Value) error) error {
+	v := reflect.ValueOf(obj)
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return errors.New("obj 不能为 nil")
+	}
+
+	excluded := map[string]bool{"ExcludeMethods": true}
+	if e, ok := obj.(MethodExcluder); ok {
+		for _, name := range e.ExcludeMethods() {
+			excluded[name] = true
+		}
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if m.PkgPath != "" || excluded[m.Name] {
+			continue
+		}
+		if err := f(namespace+"."+MethodName(m.Name), v.Method(i)); err != nil {
+			return errors.New(m.Name + ": " + err.Error())
+		}
+	}
+
+	// 嵌套的命名空间
+	sv := reflect.Indirect(v)
+	if sv.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < sv.NumField(); i++ {
+		field := sv.Type().Field(i)
+		tag := field.Tag.Get("xwebview")
+		if tag == "" || tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		fv := sv.Field(i)
+		if (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) && fv.IsNil() {
+			continue
+		}
+		if fv.Kind() == reflect.Struct && fv.CanAddr() {
+			fv = fv.Addr()
+		}
+		if err := Object(namespace+"."+tag, fv.Interface(), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MethodName 把 Go 方法名转为 js 中的驼峰命名, 如 MoveWindow -> moveWindow, HTTPGet -> httpGet, ID -> id.
+func MethodName(name string) string {
+	r := []rune(name)
+	for i := 0; i < len(r) && unicode.IsUpper(r[i]); i++ {
+		// 连续大写字母的最后一个如果后面跟着小写字母, 则属于下一个单词
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
@@ -0,0 +1,79 @@
+package xwebview
+
+import (
+	"reflect"
+
+	"github.com/twgh/xwebview/pkg/tsgen"
+)
+
+// bindingsMethod 是 js 端的 xwebview.bindings() 对应的内置函数.
+const bindingsMethod = "xwebview.bindings"
+
+// BindingList 是已绑定的函数的描述, 由 Bindings 和 js 端的 xwebview.bindings() 返回.
+type BindingList struct {
+	// Bindings 是所有绑定的函数, 按名称排序.
+	Bindings []BindingInfo `json:"bindings"`
+	// Types 是参数和返回值中的结构体生成的 TypeScript interface 声明.
+	Types []string `json:"types,omitempty"`
+}
+
+// BindingInfo 是一个绑定的函数的描述, 类型是 TypeScript 类型, 规则见 tsgen 包.
+type BindingInfo struct {
+	// Name 是绑定时的名称, 如 "app.getVersion".
+	Name string `json:"name"`
+	// Params 是 js 端的参数, 不包括 context.Context, *Caller 等由 xwebview 传入的参数.
+	Params []ParamInfo `json:"params"`
+	// Returns 是 js 端的返回值类型, 总是 Promise, 如 "Promise<string>".
+	Returns string `json:"returns"`
+	// Stream 表示函数返回通道, 在 js 端是异步迭代器.
+	Stream bool `json:"stream,omitempty"`
+	// Description 是绑定时 BindOption.Description 设置的说明.
+	Description string `json:"description,omitempty"`
+}
+
+// ParamInfo 是函数的一个参数的描述.
+type ParamInfo struct {
+	// Name 是参数名, 反射无法得到 Go 中的参数名, 所以是 arg0, arg1 ..., 可取消调用的函数末尾是 signal.
+	Name string `json:"name"`
+	// Type 是 TypeScript 类型.
+	Type string `json:"type"`
+	// Optional 表示参数可以不传.
+	Optional bool `json:"optional,omitempty"`
+	// Variadic 表示是可变参数, Type 是每个元素的类型.
+	Variadic bool `json:"variadic,omitempty"`
+}
+
+// Bindings 返回已绑定的函数的名称、参数类型、返回值类型和说明, 不包括 xwebview 自带的函数.
+//
+// js 端可以调用 window.xwebview.bindings() 得到同样的内容, 用于检测功能是否存在或实现通用的调试控制台.
+func (w *WebView) Bindings() BindingList {
+	g := tsgen.New()
+	list := BindingList{Bindings: []BindingInfo{}}
+	for _, name := range w.ListBindings() {
+		b, ok := w.getBinding(name)
+		if !ok {
+			continue
+		}
+		t := reflect.TypeOf(b.f)
+		info := BindingInfo{Name: name, Returns: g.Returns(t), Stream: b.stream, Description: b.description}
+		for _, p := range g.Params(t) {
+			info.Params = append(info.Params, ParamInfo{Name: p.Name, Type: p.Type, Optional: p.Optional, Variadic: p.Variadic})
+		}
+		if info.Params == nil {
+			info.Params = []ParamInfo{}
+		}
+		list.Bindings = append(list.Bindings, info)
+	}
+	list.Types = g.Declarations()
+	return list
+}
+
+// bindBuiltin 绑定 xwebview 自带的函数, 它不出现在 ListBindings, Bindings 和 WriteTypeScript 中. 必须在UI线程执行.
+func (w *WebView) bindBuiltin(name string, f interface{}) {
+	if err := w.bind(name, f, BindOption{}); err != nil {
+		return
+	}
+	w.m.Lock()
+	w.bindings[name].builtin = true
+	w.m.Unlock()
+}
@@ -0,0 +1,318 @@
+package xwebview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/twgh/xwebview/pkg/tsgen"
+)
+
+var (
+	// ErrJSFuncReleased 是 js 函数已经被释放, 或者它所在的页面已经离开.
+	ErrJSFuncReleased = errors.New("js 函数已被释放")
+	// ErrUIThreadDeadlock 是在UI线程等待 js 的结果, js 的结果需要UI线程处理消息才能返回, 所以会死锁.
+	ErrUIThreadDeadlock = errors.New("不能在UI线程等待 js 的结果")
+)
+
+var jsFuncType = reflect.TypeOf(JSFunc{})
+
+func init() {
+	tsgen.RegisterType(jsFuncType, "((...args: any[]) => unknown)")
+}
+
+// JSFunc 是 js 端作为参数传给绑定的函数的 js 函数, 可以在 Go 中调用它, 如报告进度.
+//
+// 绑定的函数的参数(或参数中结构体的字段、切片的元素)声明为 JSFunc 或 *JSFunc 即可接收 js 函数.
+// js 端的函数保存在页面的回调表中, 不再使用时应该调用 Release, 页面离开时会全部失效.
+// 参数中没有变为 JSFunc 交给函数的 js 函数(如调用被拒绝或参数没有通过验证)在调用结束后自动释放.
+type JSFunc struct {
+	// ID 是 js 端回调表中的 ID.
+	ID string `json:"__jsfunc"`
+	w  *WebView
+}
+
+// JSError 是 js 代码抛出的异常, 由 JSFunc.Call 和 EvalSync, EvalRaw, EvalInto 等返回, 可以用 errors.As 取得.
+type JSError struct {
+	// Name 是异常的类型, 如 "TypeError".
+	Name string `json:"name"`
+	// Message 是异常的信息.
+	Message string `json:"message"`
+	// Stack 是 js 的调用栈, 可能为空.
+	Stack string `json:"stack,omitempty"`
+}
+
+func (e *JSError) Error() string {
+	if e.Name == "" {
+		return e.Message
+	}
+	return e.Name + ": " + e.Message
+}
+
+// jsFuncMessage 是调用或释放 js 函数的消息.
+type jsFuncMessage struct {
+	Type    string        `json:"type"`
+	Func    string        `json:"func"`
+	Seq     int           `json:"seq,omitempty"`
+	Args    []interface{} `json:"args,omitempty"`
+	Release bool          `json:"release,omitempty"`
+}
+
+// jsFuncResult 是 js 函数的返回值.
+type jsFuncResult struct {
+	result json.RawMessage
+	err    error
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler. js 端传来的是 {"__jsfunc": id}.
+func (f *JSFunc) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var v struct {
+		ID *string `json:"__jsfunc"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil || v.ID == nil {
+		return errors.New("参数不是 js 函数")
+	}
+	f.ID = *v.ID
+	return nil
+}
+
+// Call 调用 js 函数, 等待并返回它的返回值的 json, 返回 Promise 时等待 Promise 完成. 不能在UI线程调用.
+//
+// args: 参数, 会被转换为 json.
+//
+// js 函数抛出异常时返回 *JSError, 函数已经被释放或页面已经离开时返回 ErrJSFuncReleased, 在UI线程调用时返回 ErrUIThreadDeadlock.
+func (f *JSFunc) Call(args ...interface{}) (json.RawMessage, error) {
+	return f.CallContext(context.Background(), args...)
+}
+
+// CallContext 与 Call 相同, ctx 被取消或超过截止时间时不再等待, 返回 ctx.Err(). js 函数不会被中止.
+func (f *JSFunc) CallContext(ctx context.Context, args ...interface{}) (json.RawMessage, error) {
+	w := f.w
+	if w == nil || f.ID == "" {
+		return nil, ErrJSFuncReleased
+	}
+	if w.IsUIThread() {
+		return nil, ErrUIThreadDeadlock
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	pageCtx, _ := w.pageContext()
+	ch := make(chan jsFuncResult, 1)
+	w.callsMux.Lock()
+	w.jsCallID++
+	seq := w.jsCallID
+	w.jsCalls[seq] = ch
+	w.callsMux.Unlock()
+	defer func() {
+		w.callsMux.Lock()
+		delete(w.jsCalls, seq)
+		w.callsMux.Unlock()
+	}()
+
+	var err error
+	w.ui(func() {
+		err = w.post(jsFuncMessage{Type: "jsfunc", Func: f.ID, Seq: seq, Args: args})
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-ch:
+		return r.result, r.err
+	case <-pageCtx.Done():
+		return nil, ErrJSFuncReleased
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Release 从 js 端的回调表中删除函数, 之后不能再调用. 可以在任意线程调用.
+func (f *JSFunc) Release() {
+	w := f.w
+	if w == nil || f.ID == "" {
+		return
+	}
+	f.w = nil
+	w.ui(func() {
+		_ = w.post(jsFuncMessage{Type: "jsfunc", Func: f.ID, Release: true})
+	})
+}
+
+// resolveJSFunc 把 js 函数的返回值交给等待的 Call. 在UI线程执行.
+//
+// 每个 seq 只接受第一个结果, 重复或伪造的 {callback: seq} 被忽略, 不会阻塞UI线程.
+func (w *WebView) resolveJSFunc(seq int, result json.RawMessage, jsErr *JSError) {
+	resolveCall(&w.callsMux, w.jsCalls, seq, result, jsErr)
+}
+
+// resolveCall 把结果交给 calls 中 seq 对应的通道并删除它, 不会阻塞. mu 是保护 calls 的锁.
+func resolveCall(mu *sync.Mutex, calls map[int]chan jsFuncResult, seq int, result json.RawMessage, jsErr *JSError) {
+	mu.Lock()
+	ch := calls[seq]
+	delete(calls, seq)
+	mu.Unlock()
+	if ch == nil {
+		return
+	}
+	r := jsFuncResult{result: result}
+	if jsErr != nil {
+		r.err = jsErr
+	}
+	select {
+	case ch <- r:
+	default:
+	}
+}
+
+// jsFuncTypes 缓存类型中是否包含 JSFunc.
+var jsFuncTypes sync.Map
+
+// hasJSFunc 判断类型为 t 的值中是否可能包含 JSFunc.
+func hasJSFunc(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if v, ok := jsFuncTypes.Load(t); ok {
+		return v.(bool)
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	found := false
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == jsFuncType {
+			found = true
+			break
+		}
+		for i := 0; i < t.NumField() && !found; i++ {
+			found = t.Field(i).PkgPath == "" && hasJSFunc(t.Field(i).Type, visiting)
+		}
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		found = hasJSFunc(t.Elem(), visiting)
+	}
+	delete(visiting, t)
+	jsFuncTypes.Store(t, found)
+	return found
+}
+
+// attachJSFuncs 把 v 中所有 JSFunc 关联到 w, 并把它们的 ID 追加到 ids. v 必须是可寻址的.
+func (w *WebView) attachJSFuncs(v reflect.Value, ids *[]string) {
+	if !hasJSFunc(v.Type(), map[reflect.Type]bool{}) {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			w.attachJSFuncs(v.Elem(), ids)
+		}
+	case reflect.Struct:
+		if v.Type() == jsFuncType {
+			f := v.Addr().Interface().(*JSFunc)
+			f.w = w
+			if f.ID != "" {
+				*ids = append(*ids, f.ID)
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				w.attachJSFuncs(v.Field(i), ids)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			w.attachJSFuncs(v.Index(i), ids)
+		}
+	case reflect.Map:
+		// map 的元素不可寻址, 复制出来修改后再放回去
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			w.attachJSFuncs(e, ids)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
+
+// jsFuncRefs 记录一次调用的参数中的 js 函数. js 端把每个作为参数的函数都放入回调表,
+// 只有交给了绑定的函数的 JSFunc 由它负责释放, 其他的(如参数不是 JSFunc, 调用被拒绝或没有通过验证)在调用结束后由 release 释放.
+type jsFuncRefs struct {
+	ids  []string
+	kept map[string]bool // 交给了绑定的函数的 js 函数, 在调用函数的协程中设置
+}
+
+// newJSFuncRefs 找出参数中所有的 {"__jsfunc": id}, 没有时返回 nil.
+func newJSFuncRefs(params ...json.RawMessage) *jsFuncRefs {
+	var ids []string
+	for _, p := range params {
+		if !bytes.Contains(p, []byte(`"__jsfunc"`)) {
+			continue
+		}
+		var v interface{}
+		if json.Unmarshal(p, &v) == nil {
+			ids = appendJSFuncIDs(ids, v)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return &jsFuncRefs{ids: ids}
+}
+
+func appendJSFuncIDs(ids []string, v interface{}) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if id, ok := v["__jsfunc"].(string); ok {
+			return append(ids, id)
+		}
+		for _, e := range v {
+			ids = appendJSFuncIDs(ids, e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			ids = appendJSFuncIDs(ids, e)
+		}
+	}
+	return ids
+}
+
+// keep 记录交给了绑定的函数的 js 函数.
+func (r *jsFuncRefs) keep(ids []string) {
+	if r == nil || len(ids) == 0 {
+		return
+	}
+	if r.kept == nil {
+		r.kept = map[string]bool{}
+	}
+	for _, id := range ids {
+		r.kept[id] = true
+	}
+}
+
+// releaseJSFuncs 在调用结束后释放 r 中没有交给绑定的函数的 js 函数. r 可以为 nil.
+func (w *WebView) releaseJSFuncs(r *jsFuncRefs) {
+	if r == nil {
+		return
+	}
+	var ids []string
+	for _, id := range r.ids {
+		if !r.kept[id] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	w.ui(func() {
+		for _, id := range ids {
+			_ = w.post(jsFuncMessage{Type: "jsfunc", Func: id, Release: true})
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package xwebview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// JSON-RPC 2.0 的标准错误码.
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcInternalError  = -32603
+	jsonrpcServerError    = -32000
+)
+
+// jsonrpcResponse 是 JSON-RPC 2.0 的响应.
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// jsonrpcError 是 JSON-RPC 2.0 的错误对象, Data 中保存 GoError 的 code 和 data.
+type jsonrpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// jsonrpcErrorData 是 jsonrpcError 的 Data, 在 js 端用于还原 GoError.
+type jsonrpcErrorData struct {
+	Code string      `json:"code"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// isBatch 判断消息是否是 JSON-RPC 2.0 的批量请求.
+func isBatch(msg []byte) bool {
+	msg = bytes.TrimSpace(msg)
+	return len(msg) > 0 && msg[0] == '['
+}
+
+// newJSONRPCError 把 err 转换为 JSON-RPC 的错误对象.
+func newJSONRPCError(err error) *jsonrpcError {
+	obj := toErrorObject(err)
+	code := jsonrpcServerError
+	switch obj.Code {
+	case ErrCodeInvalidArgs:
+		code = jsonrpcInvalidParams
+	case ErrCodePanic, ErrCodeBadResult:
+		code = jsonrpcInternalError
+	}
+	return &jsonrpcError{Code: code, Message: obj.Message, Data: jsonrpcErrorData{Code: obj.Code, Data: obj.Data}}
+}
+
+// marshalResponse 把响应转换为 json, 错误的附加数据无法转换时会被丢弃.
+func marshalResponse(resp *jsonrpcResponse) json.RawMessage {
+	resp.JSONRPC = "2.0"
+	if len(resp.ID) == 0 {
+		resp.ID = json.RawMessage("null")
+	}
+	b, err := json.Marshal(resp)
+	if err != nil && resp.Error != nil {
+		resp.Error.Data = nil
+		b, err = json.Marshal(resp)
+	}
+	if err != nil {
+		b, _ = json.Marshal(jsonrpcResponse{JSONRPC: "2.0", ID: resp.ID, Error: &jsonrpcError{Code: jsonrpcInternalError, Message: err.Error()}})
+	}
+	return b
+}
+
+// handleJSONRPC 处理 JSON-RPC 2.0 的请求, 批量请求的所有响应在全部调用结束后通过一条消息返回.
+func (w *WebView) handleJSONRPC(msg []byte, caller *Caller) {
+	var reqs []json.RawMessage
+	batch := isBatch(msg)
+	if !batch {
+		reqs = []json.RawMessage{msg}
+	} else if err := json.Unmarshal(msg, &reqs); err != nil {
+		w.postJSONRPC(false, []json.RawMessage{marshalResponse(&jsonrpcResponse{Error: &jsonrpcError{Code: jsonrpcParseError, Message: err.Error()}})})
+		return
+	} else if len(reqs) == 0 {
+		w.postJSONRPC(false, []json.RawMessage{marshalResponse(&jsonrpcResponse{Error: &jsonrpcError{Code: jsonrpcInvalidRequest, Message: "empty batch"}})})
+		return
+	}
+
+	ctx, page := w.pageContext()
+	ctx = withCaller(ctx, caller)
+	var mu sync.Mutex
+	results := make([]json.RawMessage, len(reqs))
+	pending := len(reqs) + 1
+	finish := func(i int, resp json.RawMessage) {
+		mu.Lock()
+		if i >= 0 {
+			results[i] = resp
+		}
+		pending--
+		last := pending == 0
+		mu.Unlock()
+		if !last {
+			return
+		}
+		// 通知没有响应
+		list := make([]json.RawMessage, 0, len(results))
+		for _, r := range results {
+			if r != nil {
+				list = append(list, r)
+			}
+		}
+		if len(list) == 0 {
+			return
+		}
+		w.ui(func() {
+			if w.isCurrentPage(page) {
+				w.postJSONRPC(batch, list)
+			}
+		})
+	}
+	for i, raw := range reqs {
+		i := i
+		w.callJSONRPC(ctx, raw, caller, func(resp json.RawMessage) {
+			finish(i, resp)
+		})
+	}
+	finish(-1, nil)
+}
+
+// postJSONRPC 把响应发送给 js 端. 必须在UI线程执行.
+func (w *WebView) postJSONRPC(batch bool, list []json.RawMessage) {
+	var data interface{} = list
+	if !batch {
+		data = list[0]
+	}
+	_ = w.post(pushMessage{Type: "jsonrpc", Data: data})
+}
+
+// callJSONRPC 执行一个 JSON-RPC 请求, 结束后调用 cb, 通知的 resp 为 nil.
+func (w *WebView) callJSONRPC(ctx context.Context, raw json.RawMessage, caller *Caller, cb func(resp json.RawMessage)) {
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &req); err != nil {
+		cb(marshalResponse(&jsonrpcResponse{Error: &jsonrpcError{Code: jsonrpcInvalidRequest, Message: "request must be an object"}}))
+		return
+	}
+	id, hasID := req["id"]
+	// 调用没有执行或结束后, 释放参数中没有交给函数的 js 函数
+	funcs := newJSFuncRefs(req["params"])
+	// 无效的请求无法判断是否是通知, 总是响应
+	invalid := func(message string) {
+		w.releaseJSFuncs(funcs)
+		cb(marshalResponse(&jsonrpcResponse{ID: id, Error: &jsonrpcError{Code: jsonrpcInvalidRequest, Message: message}}))
+	}
+	// 通知不能有任何响应, 包括错误
+	failError := func(e *jsonrpcError) {
+		w.releaseJSFuncs(funcs)
+		if !hasID {
+			cb(nil)
+			return
+		}
+		cb(marshalResponse(&jsonrpcResponse{ID: id, Error: e}))
+	}
+	fail := func(code int, message string) {
+		failError(&jsonrpcError{Code: code, Message: message})
+	}
+
+	var version, method string
+	if json.Unmarshal(req["jsonrpc"], &version) != nil || version != "2.0" {
+		invalid(`jsonrpc must be "2.0"`)
+		return
+	}
+	if json.Unmarshal(req["method"], &method) != nil || method == "" {
+		invalid("method must be a string")
+		return
+	}
+
+	b, ok := w.getBinding(method)
+	if err := w.checkCaller(caller, b); err != nil {
+		failError(newJSONRPCError(err))
+		return
+	}
+	if !ok {
+		fail(jsonrpcMethodNotFound, "method not found: "+method)
+		return
+	}
+	if b.stream {
+		fail(jsonrpcServerError, "bindings that return a channel can not be called with JSON-RPC")
+		return
+	}
+
+	// 参数可以是数组, 也可以是对象, 对象只能传给只有一个参数的函数
+	d := rpcMessage{Method: method, caller: caller, funcs: funcs}
+	params := bytes.TrimSpace(req["params"])
+	switch {
+	case len(params) == 0:
+	case params[0] == '[':
+		if err := json.Unmarshal(params, &d.Params); err != nil {
+			fail(jsonrpcInvalidParams, err.Error())
+			return
+		}
+	case params[0] == '{':
+		if t := reflect.TypeOf(b.f); t.IsVariadic() || t.NumIn() != b.offset()+1 {
+			fail(jsonrpcInvalidParams, "named params can only be passed to a function with one parameter")
+			return
+		}
+		d.Params = []json.RawMessage{params}
+	default:
+		fail(jsonrpcInvalidParams, "params must be an array or an object")
+		return
+	}
+
+	release, err := w.admit(b)
+	if err != nil {
+		failError(newJSONRPCError(err))
+		return
+	}
+	cancel := func(err error) {
+		release()
+		failError(newJSONRPCError(err))
+	}
+	err = w.execute(b, func() {
+		res, err := w.callbinding(ctx, b, d)
+		w.releaseJSFuncs(funcs)
+		release()
+		if !hasID {
+			cb(nil)
+			return
+		}
+		resp := &jsonrpcResponse{ID: id}
+		if err == nil {
+			if resp.Result, err = json.Marshal(res); err != nil {
+				err = NewError(ErrCodeBadResult, err.Error())
+			}
+		}
+		if err != nil {
+			resp.Result = nil
+			resp.Error = newJSONRPCError(err)
+		}
+		out := marshalResponse(resp)
+		if !b.builtin {
+			w.metrics.response(method, len(out))
+		}
+		cb(out)
+	}, cancel)
+	if err != nil {
+		cancel(err)
+	}
+}
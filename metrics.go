@@ -0,0 +1,261 @@
+package xwebview
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets 是耗时直方图的桶的上界, 单位秒.
+var LatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsSnapshot 是绑定的函数的调用统计的快照, 由 Metrics 返回.
+type MetricsSnapshot struct {
+	// Methods 是每个函数的统计, 按名称排序, 只包括被调用过的函数.
+	Methods []MethodMetrics
+	// InFlight 是所有函数正在执行的调用数量.
+	InFlight int
+}
+
+// MethodMetrics 是一个函数的调用统计.
+type MethodMetrics struct {
+	// Method 是绑定时的名称.
+	Method string
+	// Calls 是已经结束的调用次数.
+	Calls uint64
+	// Errors 是返回错误的调用次数, 包括 panic 和参数错误.
+	Errors uint64
+	// ErrorCodes 是每个错误码的次数, 错误码见 CodedError.
+	ErrorCodes map[string]uint64
+	// InFlight 是正在执行的调用数量.
+	InFlight int
+	// Latency 是函数执行的耗时, 包括 Middleware 和参数转换, 不包括在协程池中等待的时间. 返回通道的函数只统计到返回通道为止.
+	Latency Histogram
+	// RequestBytes 是 js 端传入的参数的 json 的总字节数.
+	RequestBytes uint64
+	// ResponseBytes 是返回给 js 端的结果编码后的总字节数.
+	ResponseBytes uint64
+}
+
+// Histogram 是耗时的直方图.
+type Histogram struct {
+	// Bounds 是桶的上界, 单位秒, 与 LatencyBuckets 相同.
+	Bounds []float64
+	// Counts 是落在每个桶中的次数, 不是累计的. 比 Bounds 多一个元素, 最后一个是超过所有上界的次数.
+	Counts []uint64
+	// Count 是总次数.
+	Count uint64
+	// Sum 是总耗时, 单位秒.
+	Sum float64
+}
+
+// CallTrace 是一次调用结束后传给 CallTracer 的信息.
+type CallTrace struct {
+	// Method 是绑定时的名称.
+	Method string
+	// Caller 是调用者的信息, 可能为 nil.
+	Caller *Caller
+	// Start 是开始执行的时间.
+	Start time.Time
+	// Duration 是执行的耗时.
+	Duration time.Duration
+	// RequestBytes 是参数的 json 的字节数.
+	RequestBytes int
+	// Err 是函数返回的错误, 为 nil 表示成功.
+	Err error
+}
+
+// CallTracer 在每次绑定的函数执行结束后被调用, 在执行函数的线程或协程中调用, 不应该阻塞.
+type CallTracer func(t CallTrace)
+
+// SetCallTracer 设置调用结束后的回调函数, 可用于输出日志或对接链路追踪, 为 nil 时不回调.
+func (w *WebView) SetCallTracer(f CallTracer) *WebView {
+	w.m.Lock()
+	w.tracer = f
+	w.m.Unlock()
+	return w
+}
+
+// Metrics 返回绑定的函数的调用统计的快照. 不包括 xwebview 自带的函数.
+func (w *WebView) Metrics() MetricsSnapshot {
+	return w.metrics.snapshot()
+}
+
+// WriteMetrics 把调用统计以 Prometheus 的文本格式写入 out, 可以在 HTTP 处理函数中调用.
+func (w *WebView) WriteMetrics(out io.Writer) error {
+	return w.Metrics().WritePrometheus(out)
+}
+
+// metrics 收集所有函数的调用统计.
+type metrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodMetrics
+}
+
+func newMetrics() *metrics {
+	return &metrics{methods: map[string]*MethodMetrics{}}
+}
+
+// get 返回 method 的统计, 必须持有 mu.
+func (m *metrics) get(method string) *MethodMetrics {
+	mm := m.methods[method]
+	if mm == nil {
+		mm = &MethodMetrics{
+			Method:     method,
+			ErrorCodes: map[string]uint64{},
+			Latency:    Histogram{Bounds: LatencyBuckets, Counts: make([]uint64, len(LatencyBuckets)+1)},
+		}
+		m.methods[method] = mm
+	}
+	return mm
+}
+
+// begin 记录一次调用开始, 返回开始时间.
+func (m *metrics) begin(method string, reqBytes int) time.Time {
+	m.mu.Lock()
+	mm := m.get(method)
+	mm.InFlight++
+	mm.RequestBytes += uint64(reqBytes)
+	m.mu.Unlock()
+	return time.Now()
+}
+
+// end 记录一次调用结束.
+func (m *metrics) end(method string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mm := m.get(method)
+	mm.InFlight--
+	mm.Calls++
+	if err != nil {
+		mm.Errors++
+		mm.ErrorCodes[toErrorObject(err).Code]++
+	}
+	sec := d.Seconds()
+	h := &mm.Latency
+	i := sort.SearchFloat64s(h.Bounds, sec)
+	h.Counts[i]++
+	h.Count++
+	h.Sum += sec
+}
+
+// response 记录返回给 js 端的结果的字节数.
+func (m *metrics) response(method string, n int) {
+	m.mu.Lock()
+	m.get(method).ResponseBytes += uint64(n)
+	m.mu.Unlock()
+}
+
+func (m *metrics) snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var s MetricsSnapshot
+	for _, mm := range m.methods {
+		c := *mm
+		c.ErrorCodes = make(map[string]uint64, len(mm.ErrorCodes))
+		for k, v := range mm.ErrorCodes {
+			c.ErrorCodes[k] = v
+		}
+		c.Latency.Counts = append([]uint64(nil), mm.Latency.Counts...)
+		s.Methods = append(s.Methods, c)
+		s.InFlight += c.InFlight
+	}
+	sort.Slice(s.Methods, func(i, j int) bool { return s.Methods[i].Method < s.Methods[j].Method })
+	return s
+}
+
+// trace 记录一次调用的开始, 返回在调用结束后执行的函数. xwebview 自带的函数不统计.
+func (w *WebView) trace(b *binding, d rpcMessage) func(err error) {
+	if b.builtin {
+		return func(error) {}
+	}
+	reqBytes := 0
+	for _, p := range d.Params {
+		reqBytes += len(p)
+	}
+	start := w.metrics.begin(d.Method, reqBytes)
+	return func(err error) {
+		dur := time.Since(start)
+		w.metrics.end(d.Method, dur, err)
+		w.m.Lock()
+		f := w.tracer
+		w.m.Unlock()
+		if f == nil {
+			return
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("xwebview: panic in CallTracer: %v", r)
+			}
+		}()
+		f(CallTrace{Method: d.Method, Caller: d.caller, Start: start, Duration: dur, RequestBytes: reqBytes, Err: err})
+	}
+}
+
+// WritePrometheus 把快照以 Prometheus 的文本格式写入 out, 指标名以 xwebview_binding_ 开头, 标签 method 是函数名.
+func (s MetricsSnapshot) WritePrometheus(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	header := func(name, typ, help string) {
+		bw.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " " + typ + "\n")
+	}
+	line := func(name, labels string, v string) {
+		bw.WriteString(name + "{" + labels + "} " + v + "\n")
+	}
+	u := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+
+	header("xwebview_binding_calls_total", "counter", "Number of finished binding calls.")
+	for _, m := range s.Methods {
+		line("xwebview_binding_calls_total", label("method", m.Method), u(m.Calls))
+	}
+	header("xwebview_binding_errors_total", "counter", "Number of binding calls that returned an error, by error code.")
+	for _, m := range s.Methods {
+		codes := make([]string, 0, len(m.ErrorCodes))
+		for c := range m.ErrorCodes {
+			codes = append(codes, c)
+		}
+		sort.Strings(codes)
+		for _, c := range codes {
+			line("xwebview_binding_errors_total", label("method", m.Method)+","+label("code", c), u(m.ErrorCodes[c]))
+		}
+	}
+	header("xwebview_binding_in_flight", "gauge", "Number of binding calls currently executing.")
+	for _, m := range s.Methods {
+		line("xwebview_binding_in_flight", label("method", m.Method), strconv.Itoa(m.InFlight))
+	}
+	header("xwebview_binding_duration_seconds", "histogram", "Binding call latency in seconds.")
+	for _, m := range s.Methods {
+		ml := label("method", m.Method)
+		var cum uint64
+		for i, c := range m.Latency.Counts {
+			cum += c
+			le := "+Inf"
+			if i < len(m.Latency.Bounds) {
+				le = f(m.Latency.Bounds[i])
+			}
+			line("xwebview_binding_duration_seconds_bucket", ml+","+label("le", le), u(cum))
+		}
+		line("xwebview_binding_duration_seconds_sum", ml, f(m.Latency.Sum))
+		line("xwebview_binding_duration_seconds_count", ml, u(m.Latency.Count))
+	}
+	header("xwebview_binding_request_bytes_total", "counter", "Total size of binding call arguments in bytes.")
+	for _, m := range s.Methods {
+		line("xwebview_binding_request_bytes_total", label("method", m.Method), u(m.RequestBytes))
+	}
+	header("xwebview_binding_response_bytes_total", "counter", "Total size of encoded binding results in bytes.")
+	for _, m := range s.Methods {
+		line("xwebview_binding_response_bytes_total", label("method", m.Method), u(m.ResponseBytes))
+	}
+	return bw.Flush()
+}
+
+// label 返回 Prometheus 的标签, 转义值中的反斜杠、引号和换行.
+func label(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return name + `="` + value + `"`
+}
@@ -0,0 +1,57 @@
+package xwebview
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Call 是一次 js 端对绑定的函数的调用, 传给 Middleware.
+type Call struct {
+	// Method 是绑定时的名称, 如 "app.getVersion".
+	Method string
+	// Params 是 js 端传入的参数, 每个元素是一个参数的 json. 在调用 next 之前修改它会改变函数收到的参数.
+	Params []json.RawMessage
+	// Caller 是调用者的信息.
+	Caller *Caller
+	// Context 是这次调用的 context, 在页面离开、WebView 销毁或 js 端取消调用时被取消.
+	Context context.Context
+}
+
+// Middleware 包裹每一次绑定函数的调用, 可以用来记录日志、统计耗时、鉴权等.
+//
+// next 执行下一个 Middleware, 最后执行绑定的函数, 返回函数的结果和错误.
+// Middleware 的返回值会代替 next 的返回值返回给 js 端, 不调用 next 时函数不会被执行.
+type Middleware func(call *Call, next func() (interface{}, error)) (interface{}, error)
+
+// Use 添加 Middleware, 先添加的在外层. 对所有绑定的函数生效, 包括 JSON-RPC 的调用.
+func (w *WebView) Use(m ...Middleware) *WebView {
+	w.m.Lock()
+	list := make([]Middleware, 0, len(w.middlewares)+len(m))
+	list = append(list, w.middlewares...)
+	w.middlewares = append(list, m...)
+	w.m.Unlock()
+	return w
+}
+
+// intercept 经过所有 Middleware 调用 b.
+func (w *WebView) intercept(ctx context.Context, b *binding, d rpcMessage) (interface{}, error) {
+	w.m.Lock()
+	list := w.middlewares
+	w.m.Unlock()
+	if len(list) == 0 {
+		return w.invoke(ctx, b, d)
+	}
+
+	call := &Call{Method: d.Method, Params: d.Params, Caller: d.caller, Context: ctx}
+	var next func(i int) func() (interface{}, error)
+	next = func(i int) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			if i == len(list) {
+				d.Params = call.Params
+				return w.invoke(ctx, b, d)
+			}
+			return list[i](call, next(i+1))
+		}
+	}
+	return next(0)()
+}
@@ -0,0 +1,206 @@
+package xwebview
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twgh/xwebview/pkg/edge"
+)
+
+// NavigationError 是导航失败, 由 NavigateContext, SetHtmlContext 和 WaitForLoad 返回.
+type NavigationError struct {
+	// Status 是 WebView2 的 COREWEBVIEW2_WEB_ERROR_STATUS, 如 edge.COREWEBVIEW2_WEB_ERROR_STATUS_HOST_NAME_NOT_RESOLVED.
+	Status edge.COREWEBVIEW2_WEB_ERROR_STATUS
+	// NavigationID 是失败的导航的 ID.
+	NavigationID uint64
+}
+
+func (e *NavigationError) Error() string {
+	return "导航失败, WebErrorStatus: " + strconv.Itoa(int(e.Status))
+}
+
+// onNavigationStarting 记录最近开始的导航. 重定向时同一个导航会再次触发, 只计数一次.
+func (w *WebView) onNavigationStarting(_ *edge.ICoreWebView2, args *edge.ICoreWebView2NavigationStartingEventArgs) {
+	id, _ := args.GetNavigationId()
+	w.callsMux.Lock()
+	defer w.callsMux.Unlock()
+	if id != w.startedNavID {
+		w.startedNavID = id
+		w.navStarted++
+	}
+}
+
+// onNavigationCompleted 记录最近开始的导航的结果, 唤醒 WaitForLoad.
+//
+// 被取消的导航(如在 NavigationStarting 中取消)也会触发完成事件, 这时返回 NavigationError, 而不是一直等待.
+func (w *WebView) onNavigationCompleted(_ *edge.ICoreWebView2, args *edge.ICoreWebView2NavigationCompletedEventArgs) {
+	id, _ := args.GetNavigationId()
+	var err error
+	if ok, _ := args.GetIsSuccess(); !ok {
+		status, _ := args.GetWebErrorStatus()
+		err = &NavigationError{Status: status, NavigationID: id}
+	}
+	w.callsMux.Lock()
+	defer w.callsMux.Unlock()
+	// 被新的导航取代的导航也会触发完成事件, 等待的是最新的导航
+	if id != w.startedNavID {
+		return
+	}
+	w.navLoaded = w.navStarted
+	w.loadErr = err
+	close(w.loaded)
+	w.loaded = make(chan struct{})
+}
+
+// waitLoaded 等待最近开始的导航完成, 且已开始的导航数量不小于 min, 返回它的结果. 不能在UI线程调用.
+func (w *WebView) waitLoaded(ctx context.Context, min uint64) error {
+	for {
+		w.callsMux.Lock()
+		if w.navLoaded >= min && w.navLoaded == w.navStarted {
+			err := w.loadErr
+			w.callsMux.Unlock()
+			return err
+		}
+		loaded := w.loaded
+		w.callsMux.Unlock()
+
+		select {
+		case <-loaded:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.ctx.Done():
+			return ErrWebViewDestroyed
+		}
+	}
+}
+
+// navigate 在UI线程执行 start 开始导航, 等待这次导航完成. start 返回错误时直接返回它.
+//
+// url 与当前页面只有片段(#后面的部分)不同时是同文档导航, 不会触发导航事件, 不等待.
+func (w *WebView) navigate(ctx context.Context, url string, start func() error) error {
+	if w.IsUIThread() {
+		return ErrUIThreadDeadlock
+	}
+	var started uint64
+	var sameDocument bool
+	err := w.DispatchSync(func() error {
+		if url != "" {
+			source, err := w.browser.Source()
+			sameDocument = err == nil && isSameDocument(source, url)
+		}
+		w.callsMux.Lock()
+		started = w.navStarted
+		w.callsMux.Unlock()
+		return start()
+	})
+	if err != nil || sameDocument {
+		return err
+	}
+	return w.waitLoaded(ctx, started+1)
+}
+
+// isSameDocument 判断导航到 url 是否只是 source 中的片段导航.
+func isSameDocument(source, url string) bool {
+	i := strings.IndexByte(url, '#')
+	if i < 0 {
+		return false
+	}
+	if j := strings.IndexByte(source, '#'); j >= 0 {
+		source = source[:j]
+	}
+	return url[:i] == source
+}
+
+// NavigateContext 导航到 url, 等待页面加载完成(NavigationCompleted 事件)后返回. 不能在UI线程调用.
+//
+// 导航无法开始(如 url 无效)时返回它的错误, 导航失败或被取消时返回 *NavigationError,
+// ctx 被取消或超过截止时间时返回 ctx.Err(), 导航不会被停止.
+// 等待期间又开始了新的导航时, 等待最新的导航完成. 只有片段不同的 url 不等待. 在UI线程调用时返回 ErrUIThreadDeadlock.
+func (w *WebView) NavigateContext(ctx context.Context, url string) error {
+	return w.navigate(ctx, url, func() error {
+		return w.browser.Navigate(url)
+	})
+}
+
+// SetHtmlContext 设置 webview 的 HTML, 等待页面加载完成后返回. 不能在UI线程调用. 错误与 NavigateContext 相同.
+func (w *WebView) SetHtmlContext(ctx context.Context, html string) error {
+	return w.navigate(ctx, "", func() error {
+		return w.browser.NavigateToString(html)
+	})
+}
+
+// WaitForLoad 等待当前页面加载完成, 返回它的导航结果, 已经加载完成时直接返回. 不能在UI线程调用.
+//
+// 正在导航时等待导航完成, 还没有导航完成过时等待第一次导航完成. 错误与 NavigateContext 相同.
+func (w *WebView) WaitForLoad(ctx context.Context) error {
+	if w.IsUIThread() {
+		return ErrUIThreadDeadlock
+	}
+	return w.waitLoaded(ctx, 1)
+}
+
+// EvalContext 执行 js 代码, 同步取回返回值, 返回值与 EvalSync 相同. 可以在任意线程调用.
+//
+// ctx 被取消时返回 ctx.Err(), 超过截止时间时返回 ErrEvalTimeout, 它也满足 errors.Is(err, context.DeadlineExceeded).
+// 在UI线程调用时与 EvalSync 一样一边等待一边处理消息, 在其他协程调用时只在UI线程发起执行, 在当前协程等待结果.
+func (w *WebView) EvalContext(ctx context.Context, js string) (interface{}, error) {
+	result, err := w.evalContext(ctx, js, 0)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEval(result)
+}
+
+// EvalIntoContext 与 EvalInto 相同, 但使用 ctx 控制等待时间. 可以在任意线程调用. 错误见 EvalContext.
+func (w *WebView) EvalIntoContext(ctx context.Context, js string, out interface{}) error {
+	result, err := w.evalContext(ctx, js, 0)
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result, out)
+}
+
+// EvalAsyncContext 与 EvalAsync 相同, 但使用 ctx 控制等待时间, f 不为 nil 时总是会被调用一次. 可以在任意线程调用.
+func (w *WebView) EvalAsyncContext(ctx context.Context, js string, f func(result interface{}, err error)) error {
+	return w.DispatchSync(func() error {
+		if f == nil {
+			w.browser.Eval(js)
+			return nil
+		}
+		w.evalScript(ctx, js, 0, func(result json.RawMessage, err error) {
+			if err != nil {
+				f(nil, err)
+				return
+			}
+			f(decodeEval(result))
+		})
+		return nil
+	})
+}
+
+// evalContext 执行 js 表达式并等待值的 json. 在UI线程调用时一边等待一边处理消息, 其他协程调用时在当前协程等待.
+//
+// timeout: 大于0时在 ctx 的基础上增加超时.
+func (w *WebView) evalContext(ctx context.Context, js string, timeout time.Duration) (json.RawMessage, error) {
+	if w.IsUIThread() {
+		return w.evalWait(ctx, js, timeout)
+	}
+	ch := make(chan jsFuncResult, 1)
+	err := w.DispatchSync(func() error {
+		w.evalScript(ctx, js, timeout, func(result json.RawMessage, err error) {
+			ch <- jsFuncResult{result: result, err: err}
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := <-ch
+	return r.result, r.err
+}
@@ -0,0 +1,49 @@
+package edge
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type _ICoreWebView2ContentLoadingEventArgsVtbl struct {
+	_IUnknownVtbl
+	GetIsErrorPage  ComProc
+	GetNavigationId ComProc
+}
+
+type ICoreWebView2ContentLoadingEventArgs struct {
+	vtbl *_ICoreWebView2ContentLoadingEventArgsVtbl
+}
+
+func (i *ICoreWebView2ContentLoadingEventArgs) AddRef() uintptr {
+	r, _, _ := i.vtbl.AddRef.Call()
+	return r
+}
+
+func (i *ICoreWebView2ContentLoadingEventArgs) GetIsErrorPage() (bool, error) {
+	var err error
+	// BOOL is 4 bytes wide
+	var isErrorPage int32
+	_, _, err = i.vtbl.GetIsErrorPage.Call(
+		uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(&isErrorPage)),
+	)
+	if err != windows.ERROR_SUCCESS {
+		return false, err
+	}
+	return isErrorPage != 0, nil
+}
+
+func (i *ICoreWebView2ContentLoadingEventArgs) GetNavigationId() (uint64, error) {
+	var err error
+	var navigationId uint64
+	_, _, err = i.vtbl.GetNavigationId.Call(
+		uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(&navigationId)),
+	)
+	if err != windows.ERROR_SUCCESS {
+		return 0, err
+	}
+	return navigationId, nil
+}
@@ -1,5 +1,11 @@
 package edge
 
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
 type _ICoreWebView2NavigationCompletedEventArgsVtbl struct {
 	_IUnknownVtbl
 	GetIsSuccess      ComProc
@@ -15,3 +21,43 @@ func (i *ICoreWebView2NavigationCompletedEventArgs) AddRef() uintptr {
 	r, _, _ := i.vtbl.AddRef.Call()
 	return r
 }
+
+func (i *ICoreWebView2NavigationCompletedEventArgs) GetIsSuccess() (bool, error) {
+	var err error
+	// BOOL is 4 bytes wide
+	var isSuccess int32
+	_, _, err = i.vtbl.GetIsSuccess.Call(
+		uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(&isSuccess)),
+	)
+	if err != windows.ERROR_SUCCESS {
+		return false, err
+	}
+	return isSuccess != 0, nil
+}
+
+func (i *ICoreWebView2NavigationCompletedEventArgs) GetWebErrorStatus() (COREWEBVIEW2_WEB_ERROR_STATUS, error) {
+	var err error
+	var status COREWEBVIEW2_WEB_ERROR_STATUS
+	_, _, err = i.vtbl.GetWebErrorStatus.Call(
+		uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(&status)),
+	)
+	if err != windows.ERROR_SUCCESS {
+		return 0, err
+	}
+	return status, nil
+}
+
+func (i *ICoreWebView2NavigationCompletedEventArgs) GetNavigationId() (uint64, error) {
+	var err error
+	var navigationId uint64
+	_, _, err = i.vtbl.GetNavigationId.Call(
+		uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(&navigationId)),
+	)
+	if err != windows.ERROR_SUCCESS {
+		return 0, err
+	}
+	return navigationId, nil
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"unsafe"
 
@@ -27,6 +28,8 @@ type Chromium struct {
 	webResourceRequested  *iCoreWebView2WebResourceRequestedEventHandler
 	acceleratorKeyPressed *ICoreWebView2AcceleratorKeyPressedEventHandler
 	navigationCompleted   *ICoreWebView2NavigationCompletedEventHandler
+	navigationStarting    *ICoreWebView2NavigationStartingEventHandler
+	contentLoading        *ICoreWebView2ContentLoadingEventHandler
 
 	environment *ICoreWebView2Environment
 
@@ -37,11 +40,22 @@ type Chromium struct {
 	permissions      map[CoreWebView2PermissionKind]CoreWebView2PermissionState
 	globalPermission *CoreWebView2PermissionState
 
+	// pending keeps the per-call completion handlers reachable until native code has invoked them.
+	pending   map[interface{}]struct{}
+	pendingMu sync.Mutex
+
 	// Callbacks
-	MessageCallback              func(string)
+	MessageCallback func(string)
+	// MessageWithArgsCallback is like MessageCallback but also receives the event args, e.g. to check the source
+	// of the message. When set, MessageCallback is not called.
+	MessageWithArgsCallback      func(message string, args *ICoreWebView2WebMessageReceivedEventArgs)
 	WebResourceRequestedCallback func(request *ICoreWebView2WebResourceRequest, args *ICoreWebView2WebResourceRequestedEventArgs)
 	NavigationCompletedCallback  func(sender *ICoreWebView2, args *ICoreWebView2NavigationCompletedEventArgs)
-	AcceleratorKeyCallback       func(uint) bool
+	NavigationStartingCallback   func(sender *ICoreWebView2, args *ICoreWebView2NavigationStartingEventArgs)
+	// ContentLoadingCallback is called when the new document of a navigation starts loading, after the navigation
+	// is committed. It is not called for navigations that are cancelled or turn into downloads.
+	ContentLoadingCallback func(sender *ICoreWebView2, args *ICoreWebView2ContentLoadingEventArgs)
+	AcceleratorKeyCallback func(uint) bool
 }
 
 func NewChromium() *Chromium {
@@ -64,7 +78,10 @@ func NewChromium() *Chromium {
 	e.webResourceRequested = newICoreWebView2WebResourceRequestedEventHandler(e)
 	e.acceleratorKeyPressed = newICoreWebView2AcceleratorKeyPressedEventHandler(e)
 	e.navigationCompleted = newICoreWebView2NavigationCompletedEventHandler(e)
+	e.navigationStarting = newICoreWebView2NavigationStartingEventHandler(e)
+	e.contentLoading = newICoreWebView2ContentLoadingEventHandler(e)
 	e.permissions = make(map[CoreWebView2PermissionKind]CoreWebView2PermissionState)
+	e.pending = make(map[interface{}]struct{})
 
 	return e
 }
@@ -113,18 +130,36 @@ func (e *Chromium) Embed(hwnd uintptr) bool {
 	return true
 }
 
-func (e *Chromium) Navigate(url string) {
-	_, _, _ = e.webview.vtbl.Navigate.Call(
+// Navigate navigates to url. It returns an error when the navigation could not be started, e.g. for an invalid URI.
+func (e *Chromium) Navigate(url string) error {
+	_url, err := windows.UTF16PtrFromString(url)
+	if err != nil {
+		return err
+	}
+	r, _, _ := e.webview.vtbl.Navigate.Call(
 		uintptr(unsafe.Pointer(e.webview)),
-		uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(url))),
+		uintptr(unsafe.Pointer(_url)),
 	)
+	if int32(r) < 0 {
+		return windows.Errno(r)
+	}
+	return nil
 }
 
-func (e *Chromium) NavigateToString(htmlContent string) {
-	_, _, _ = e.webview.vtbl.NavigateToString.Call(
+// NavigateToString navigates to htmlContent. It returns an error when the navigation could not be started.
+func (e *Chromium) NavigateToString(htmlContent string) error {
+	_htmlContent, err := windows.UTF16PtrFromString(htmlContent)
+	if err != nil {
+		return err
+	}
+	r, _, _ := e.webview.vtbl.NavigateToString.Call(
 		uintptr(unsafe.Pointer(e.webview)),
-		uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(htmlContent))),
+		uintptr(unsafe.Pointer(_htmlContent)),
 	)
+	if int32(r) < 0 {
+		return windows.Errno(r)
+	}
+	return nil
 }
 
 func (e *Chromium) Init(script string) {
@@ -135,6 +170,135 @@ func (e *Chromium) Init(script string) {
 	)
 }
 
+// AddScriptToExecuteOnDocumentCreated works like Init, but reports the id of the added script to f once WebView2
+// has registered it. The id can be passed to RemoveScriptToExecuteOnDocumentCreated. f is called on the UI thread.
+func (e *Chromium) AddScriptToExecuteOnDocumentCreated(script string, f func(id string, err error)) {
+	h := &addScriptCompleted{e: e, cb: f}
+	h.handler = newICoreWebView2AddScriptToExecuteOnDocumentCreatedCompletedHandler(h)
+	e.hold(h)
+	r, _, _ := e.webview.vtbl.AddScriptToExecuteOnDocumentCreated.Call(
+		uintptr(unsafe.Pointer(e.webview)),
+		uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(script))),
+		uintptr(unsafe.Pointer(h.handler)),
+	)
+	if int32(r) < 0 {
+		e.release(h)
+		if f != nil {
+			f("", windows.Errno(r))
+		}
+	}
+}
+
+// RemoveScriptToExecuteOnDocumentCreated removes a script added with AddScriptToExecuteOnDocumentCreated.
+func (e *Chromium) RemoveScriptToExecuteOnDocumentCreated(id string) {
+	_, _, _ = e.webview.vtbl.RemoveScriptToExecuteOnDocumentCreated.Call(
+		uintptr(unsafe.Pointer(e.webview)),
+		uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(id))),
+	)
+}
+
+func (e *Chromium) hold(h interface{}) {
+	e.pendingMu.Lock()
+	e.pending[h] = struct{}{}
+	e.pendingMu.Unlock()
+}
+
+func (e *Chromium) release(h interface{}) {
+	e.pendingMu.Lock()
+	delete(e.pending, h)
+	e.pendingMu.Unlock()
+}
+
+// addScriptCompleted receives the result of a single AddScriptToExecuteOnDocumentCreated call.
+type addScriptCompleted struct {
+	e       *Chromium
+	handler *ICoreWebView2AddScriptToExecuteOnDocumentCreatedCompletedHandler
+	cb      func(id string, err error)
+}
+
+func (h *addScriptCompleted) QueryInterface(_, _ uintptr) uintptr {
+	return 0
+}
+
+func (h *addScriptCompleted) AddRef() uintptr {
+	return 1
+}
+
+func (h *addScriptCompleted) Release() uintptr {
+	return 1
+}
+
+func (h *addScriptCompleted) AddScriptToExecuteOnDocumentCreatedCompleted(errorCode uintptr, id string) uintptr {
+	h.e.release(h)
+	if h.cb == nil {
+		return 0
+	}
+	if int32(errorCode) < 0 {
+		h.cb("", windows.Errno(errorCode))
+	} else {
+		h.cb(id, nil)
+	}
+	return 0
+}
+
+// ExecuteScript runs script in the top-level document and reports the result to f as JSON, the way
+// JSON.stringify would serialize it ("null" for undefined, functions and exceptions). f is called on the UI thread.
+func (e *Chromium) ExecuteScript(script string, f func(resultJSON string, err error)) {
+	_script, err := windows.UTF16PtrFromString(script)
+	if err != nil {
+		if f != nil {
+			f("", err)
+		}
+		return
+	}
+	h := &executeScriptCompleted{e: e, cb: f}
+	h.handler = newICoreWebView2ExecuteScriptCompletedHandler(h)
+	e.hold(h)
+	r, _, _ := e.webview.vtbl.ExecuteScript.Call(
+		uintptr(unsafe.Pointer(e.webview)),
+		uintptr(unsafe.Pointer(_script)),
+		uintptr(unsafe.Pointer(h.handler)),
+	)
+	if int32(r) < 0 {
+		e.release(h)
+		if f != nil {
+			f("", windows.Errno(r))
+		}
+	}
+}
+
+// executeScriptCompleted receives the result of a single ExecuteScript call.
+type executeScriptCompleted struct {
+	e       *Chromium
+	handler *ICoreWebView2ExecuteScriptCompletedHandler
+	cb      func(resultJSON string, err error)
+}
+
+func (h *executeScriptCompleted) QueryInterface(_, _ uintptr) uintptr {
+	return 0
+}
+
+func (h *executeScriptCompleted) AddRef() uintptr {
+	return 1
+}
+
+func (h *executeScriptCompleted) Release() uintptr {
+	return 1
+}
+
+func (h *executeScriptCompleted) ExecuteScriptCompleted(errorCode uintptr, resultObjectAsJson string) uintptr {
+	h.e.release(h)
+	if h.cb == nil {
+		return 0
+	}
+	if int32(errorCode) < 0 {
+		h.cb("", windows.Errno(errorCode))
+	} else {
+		h.cb(resultObjectAsJson, nil)
+	}
+	return 0
+}
+
 func (e *Chromium) Eval(script string) {
 	_script, err := windows.UTF16PtrFromString(script)
 	if err != nil {
@@ -148,6 +312,40 @@ func (e *Chromium) Eval(script string) {
 	)
 }
 
+// PostWebMessageAsJSON posts a message to the page, it is received by the listeners of window.chrome.webview's
+// message event with the parsed json as event.data.
+func (e *Chromium) PostWebMessageAsJSON(json string) error {
+	_json, err := windows.UTF16PtrFromString(json)
+	if err != nil {
+		return err
+	}
+	r, _, _ := e.webview.vtbl.PostWebMessageAsJSON.Call(
+		uintptr(unsafe.Pointer(e.webview)),
+		uintptr(unsafe.Pointer(_json)),
+	)
+	if int32(r) < 0 {
+		return windows.Errno(r)
+	}
+	return nil
+}
+
+// PostWebMessageAsString posts a message to the page, it is received by the listeners of window.chrome.webview's
+// message event with the string as event.data.
+func (e *Chromium) PostWebMessageAsString(message string) error {
+	_message, err := windows.UTF16PtrFromString(message)
+	if err != nil {
+		return err
+	}
+	r, _, _ := e.webview.vtbl.PostWebMessageAsString.Call(
+		uintptr(unsafe.Pointer(e.webview)),
+		uintptr(unsafe.Pointer(_message)),
+	)
+	if int32(r) < 0 {
+		return windows.Errno(r)
+	}
+	return nil
+}
+
 func (e *Chromium) Show() error {
 	return e.controller.PutIsVisible(true)
 }
@@ -218,6 +416,16 @@ func (e *Chromium) CreateCoreWebView2ControllerCompleted(res uintptr, controller
 		uintptr(unsafe.Pointer(e.navigationCompleted)),
 		uintptr(unsafe.Pointer(&token)),
 	)
+	_, _, _ = e.webview.vtbl.AddNavigationStarting.Call(
+		uintptr(unsafe.Pointer(e.webview)),
+		uintptr(unsafe.Pointer(e.navigationStarting)),
+		uintptr(unsafe.Pointer(&token)),
+	)
+	_, _, _ = e.webview.vtbl.AddContentLoading.Call(
+		uintptr(unsafe.Pointer(e.webview)),
+		uintptr(unsafe.Pointer(e.contentLoading)),
+		uintptr(unsafe.Pointer(&token)),
+	)
 
 	_ = e.controller.AddAcceleratorKeyPressed(e.acceleratorKeyPressed, &token)
 
@@ -230,19 +438,17 @@ func (e *Chromium) CreateCoreWebView2ControllerCompleted(res uintptr, controller
 	return 0
 }
 
-func (e *Chromium) MessageReceived(sender *ICoreWebView2, args *iCoreWebView2WebMessageReceivedEventArgs) uintptr {
+func (e *Chromium) MessageReceived(_ *ICoreWebView2, args *ICoreWebView2WebMessageReceivedEventArgs) uintptr {
 	var message *uint16
 	_, _, _ = args.vtbl.TryGetWebMessageAsString.Call(
 		uintptr(unsafe.Pointer(args)),
 		uintptr(unsafe.Pointer(&message)),
 	)
-	if e.MessageCallback != nil {
+	if e.MessageWithArgsCallback != nil {
+		e.MessageWithArgsCallback(w32.Utf16PtrToString(message), args)
+	} else if e.MessageCallback != nil {
 		e.MessageCallback(w32.Utf16PtrToString(message))
 	}
-	_, _, _ = sender.vtbl.PostWebMessageAsString.Call(
-		uintptr(unsafe.Pointer(sender)),
-		uintptr(unsafe.Pointer(message)),
-	)
 	windows.CoTaskMemFree(unsafe.Pointer(message))
 	return 0
 }
@@ -343,6 +549,25 @@ func (e *Chromium) NavigationCompleted(sender *ICoreWebView2, args *ICoreWebView
 	return 0
 }
 
+func (e *Chromium) ContentLoading(sender *ICoreWebView2, args *ICoreWebView2ContentLoadingEventArgs) uintptr {
+	if e.ContentLoadingCallback != nil {
+		e.ContentLoadingCallback(sender, args)
+	}
+	return 0
+}
+
+func (e *Chromium) NavigationStarting(sender *ICoreWebView2, args *ICoreWebView2NavigationStartingEventArgs) uintptr {
+	if e.NavigationStartingCallback != nil {
+		e.NavigationStartingCallback(sender, args)
+	}
+	return 0
+}
+
+// Source returns the URI of the current top level document.
+func (e *Chromium) Source() (string, error) {
+	return e.webview.GetSource()
+}
+
 func (e *Chromium) NotifyParentWindowPositionChanged() error {
 	// It looks like the wndproc function is called before the controller initialization is complete.
 	// Because of this the controller is nil
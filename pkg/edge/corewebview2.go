@@ -151,6 +151,22 @@ func (i *ICoreWebView2) GetSettings() (*ICoreWebViewSettings, error) {
 	return settings, nil
 }
 
+// GetSource returns the URI of the current top level document.
+func (i *ICoreWebView2) GetSource() (string, error) {
+	var err error
+	var _uri *uint16
+	_, _, err = i.vtbl.GetSource.Call(
+		uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(&_uri)),
+	)
+	if err != windows.ERROR_SUCCESS {
+		return "", err
+	}
+	uri := windows.UTF16PtrToString(_uri)
+	windows.CoTaskMemFree(unsafe.Pointer(_uri))
+	return uri, nil
+}
+
 // ICoreWebView2Environment
 
 type iCoreWebView2EnvironmentVtbl struct {
@@ -213,10 +229,26 @@ type iCoreWebView2WebMessageReceivedEventArgsVtbl struct {
 	TryGetWebMessageAsString ComProc
 }
 
-type iCoreWebView2WebMessageReceivedEventArgs struct {
+type ICoreWebView2WebMessageReceivedEventArgs struct {
 	vtbl *iCoreWebView2WebMessageReceivedEventArgsVtbl
 }
 
+// GetSource returns the URI of the document that sent the message.
+func (i *ICoreWebView2WebMessageReceivedEventArgs) GetSource() (string, error) {
+	var err error
+	var _uri *uint16
+	_, _, err = i.vtbl.GetSource.Call(
+		uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(&_uri)),
+	)
+	if err != windows.ERROR_SUCCESS {
+		return "", err
+	}
+	uri := windows.UTF16PtrToString(_uri)
+	windows.CoTaskMemFree(unsafe.Pointer(_uri))
+	return uri, nil
+}
+
 // ICoreWebView2PermissionRequestedEventArgs
 
 type iCoreWebView2PermissionRequestedEventArgsVtbl struct {
@@ -286,7 +318,7 @@ func newICoreWebView2CreateCoreWebView2EnvironmentCompletedHandler(impl iCoreWeb
 
 type iCoreWebView2WebMessageReceivedEventHandlerImpl interface {
 	_IUnknownImpl
-	MessageReceived(sender *ICoreWebView2, args *iCoreWebView2WebMessageReceivedEventArgs) uintptr
+	MessageReceived(sender *ICoreWebView2, args *ICoreWebView2WebMessageReceivedEventArgs) uintptr
 }
 
 type iCoreWebView2WebMessageReceivedEventHandlerVtbl struct {
@@ -311,7 +343,7 @@ func _ICoreWebView2WebMessageReceivedEventHandlerIUnknownRelease(this *iCoreWebV
 	return this.impl.Release()
 }
 
-func _ICoreWebView2WebMessageReceivedEventHandlerInvoke(this *iCoreWebView2WebMessageReceivedEventHandler, sender *ICoreWebView2, args *iCoreWebView2WebMessageReceivedEventArgs) uintptr {
+func _ICoreWebView2WebMessageReceivedEventHandlerInvoke(this *iCoreWebView2WebMessageReceivedEventHandler, sender *ICoreWebView2, args *ICoreWebView2WebMessageReceivedEventArgs) uintptr {
 	return this.impl.MessageReceived(sender, args)
 }
 
@@ -0,0 +1,776 @@
+// Package msgpack 是 xwebview 的 MessagePack 编码, 与 xwebview 运行时中的 js 实现对应.
+//
+// 编码规则与 encoding/json 一致, 包括 json 标签、json.Marshaler 和 encoding.TextMarshaler, 区别是:
+//   - []byte 编码为 bin, 而不是 base64 字符串
+//   - 整数保持完整的 64 位精度, js 端超出安全整数范围的整数是 BigInt
+//
+// 解码时先转换为 json, 再由 encoding/json 解码, bin 转换为 base64 字符串, 所以可以解码到 []byte.
+package msgpack
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Marshal 把 v 编码为 MessagePack.
+func Marshal(v interface{}) ([]byte, error) {
+	var e encoder
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// FromJSON 把 json 转换为 MessagePack, 对象的键保持原来的顺序.
+func FromJSON(data []byte) ([]byte, error) {
+	var e encoder
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := e.fromJSON(dec); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// ToJSON 把 MessagePack 转换为 json. 数组和 map 的嵌套层数超过 10000 时返回错误.
+func ToJSON(data []byte) ([]byte, error) {
+	d := decoder{data: data}
+	var out bytes.Buffer
+	if err := d.value(&out); err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.New("msgpack: 数据末尾有多余的字节")
+	}
+	return out.Bytes(), nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) putByte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) putUint16(b byte, n uint16) {
+	e.buf = append(e.buf, b, 0, 0)
+	binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], n)
+}
+
+func (e *encoder) putUint32(b byte, n uint32) {
+	e.buf = append(e.buf, b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], n)
+}
+
+func (e *encoder) putUint64(b byte, n uint64) {
+	e.buf = append(e.buf, b, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], n)
+}
+
+func (e *encoder) putNil() {
+	e.putByte(0xc0)
+}
+
+func (e *encoder) putBool(b bool) {
+	if b {
+		e.putByte(0xc3)
+	} else {
+		e.putByte(0xc2)
+	}
+}
+
+func (e *encoder) putInt(n int64) {
+	switch {
+	case n >= 0:
+		e.putUint(uint64(n))
+	case n >= -32:
+		e.putByte(byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.putUint16(0xd1, uint16(n))
+	case n >= math.MinInt32:
+		e.putUint32(0xd2, uint32(n))
+	default:
+		e.putUint64(0xd3, uint64(n))
+	}
+}
+
+func (e *encoder) putUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.putByte(byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.putUint16(0xcd, uint16(n))
+	case n <= math.MaxUint32:
+		e.putUint32(0xce, uint32(n))
+	default:
+		e.putUint64(0xcf, n)
+	}
+}
+
+func (e *encoder) putFloat(f float64) {
+	e.putUint64(0xcb, math.Float64bits(f))
+}
+
+func (e *encoder) putString(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		e.putByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.putUint16(0xda, uint16(n))
+	default:
+		e.putUint32(0xdb, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) putBin(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.putUint16(0xc5, uint16(n))
+	default:
+		e.putUint32(0xc6, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) arrayHeader(n int) {
+	switch {
+	case n <= 15:
+		e.putByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		e.putUint16(0xdc, uint16(n))
+	default:
+		e.putUint32(0xdd, uint32(n))
+	}
+}
+
+func (e *encoder) mapHeader(n int) {
+	switch {
+	case n <= 15:
+		e.putByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		e.putUint16(0xde, uint16(n))
+	default:
+		e.putUint32(0xdf, uint32(n))
+	}
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.putNil()
+		return nil
+	}
+	t := v.Type()
+	if t == rawMessageType {
+		if v.Len() == 0 {
+			e.putNil()
+			return nil
+		}
+		return e.json(v.Bytes())
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		e.putNil()
+		return nil
+	}
+	if m, ok := marshaler(v, jsonMarshalerType); ok {
+		b, err := m.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		return e.json(b)
+	}
+	if m, ok := marshaler(v, textMarshalerType); ok {
+		b, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.putString(string(b))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.putBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.putInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.putUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return errors.New("msgpack: 不支持的浮点数 " + strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		// 与 encoding/json 一样, float32 使用最短的十进制表示
+		if v.Kind() == reflect.Float32 {
+			f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', -1, 32), 64)
+		}
+		e.putFloat(f)
+	case reflect.String:
+		e.putString(v.String())
+	case reflect.Ptr, reflect.Interface:
+		return e.encode(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.putNil()
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.putBin(v.Bytes())
+			return nil
+		}
+		return e.array(v)
+	case reflect.Array:
+		// 与 encoding/json 一样, [N]byte 是数组而不是 bin, 这样才能解码回 [N]byte
+		return e.array(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.putNil()
+			return nil
+		}
+		return e.mapValue(v)
+	case reflect.Struct:
+		return e.structValue(v)
+	default:
+		return errors.New("msgpack: 不支持的类型 " + t.String())
+	}
+	return nil
+}
+
+// marshaler 返回 v 或 v 的指针实现的接口 it.
+func marshaler(v reflect.Value, it reflect.Type) (interface{}, bool) {
+	if v.Type().Implements(it) {
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(it) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+func (e *encoder) array(v reflect.Value) error {
+	e.arrayHeader(v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) mapValue(v reflect.Value) error {
+	type kv struct {
+		k string
+		v reflect.Value
+	}
+	list := make([]kv, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		list = append(list, kv{k, iter.Value()})
+	}
+	// 与 encoding/json 一样按键排序
+	sort.Slice(list, func(i, j int) bool { return list[i].k < list[j].k })
+	e.mapHeader(len(list))
+	for _, p := range list {
+		e.putString(p.k)
+		if err := e.encode(p.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", errors.New("msgpack: 不支持的 map 键类型 " + k.Type().String())
+}
+
+func (e *encoder) structValue(v reflect.Value) error {
+	fields := cachedFields(v.Type())
+	type kv struct {
+		f *field
+		v reflect.Value
+	}
+	list := make([]kv, 0, len(fields))
+	for i := range fields {
+		f := &fields[i]
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmpty(fv)) {
+			continue
+		}
+		list = append(list, kv{f, fv})
+	}
+	e.mapHeader(len(list))
+	for _, p := range list {
+		e.putString(p.f.name)
+		if p.f.quoted {
+			if err := e.quoted(p.v); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.encode(p.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoted 编码带有 json:",string" 选项的字段.
+func (e *encoder) quoted(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		e.putString(string(b))
+		return nil
+	}
+	return e.encode(v)
+}
+
+// fieldByIndex 与 reflect.Value.FieldByIndex 相同, 经过 nil 的嵌入指针时返回 false.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// field 是结构体中参与编码的字段.
+type field struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+var fieldCache sync.Map
+
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f := typeFields(t)
+	fieldCache.Store(t, f)
+	return f
+}
+
+// typeFields 按 encoding/json 的规则返回结构体的字段, 匿名嵌入的结构体被展开, 浅层的字段优先.
+func typeFields(t reflect.Type) []field {
+	var fields []field
+	depth := map[string]int{}
+	var walk func(t reflect.Type, index []int, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if j := strings.Index(tag, ","); j >= 0 {
+				name, opts = tag[:j], tag[j+1:]
+			}
+			idx := append(append([]int{}, index...), i)
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				walk(ft, idx, visited)
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			f := field{name: name, index: idx, tagged: name != ""}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			for _, o := range strings.Split(opts, ",") {
+				switch o {
+				case "omitempty":
+					f.omitEmpty = true
+				case "string":
+					f.quoted = true
+				}
+			}
+			if d, ok := depth[f.name]; ok && d <= len(idx) {
+				continue
+			}
+			depth[f.name] = len(idx)
+			// 替换更深层的同名字段
+			for j := range fields {
+				if fields[j].name == f.name {
+					fields = append(fields[:j], fields[j+1:]...)
+					break
+				}
+			}
+			fields = append(fields, f)
+		}
+		delete(visited, t)
+	}
+	walk(t, nil, map[reflect.Type]bool{})
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i].index, fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields
+}
+
+// json 把 json 数据编码为 MessagePack.
+func (e *encoder) json(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return e.fromJSON(dec)
+}
+
+func (e *encoder) fromJSON(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		// 先编码到临时的 encoder, 知道元素个数后再写入头部
+		var sub encoder
+		n := 0
+		for dec.More() {
+			if t == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				sub.putString(key.(string))
+			}
+			if err := sub.fromJSON(dec); err != nil {
+				return err
+			}
+			n++
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		if t == '{' {
+			e.mapHeader(n)
+		} else {
+			e.arrayHeader(n)
+		}
+		e.buf = append(e.buf, sub.buf...)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			e.putInt(i)
+		} else if u, err := strconv.ParseUint(string(t), 10, 64); err == nil {
+			e.putUint(u)
+		} else if f, err := t.Float64(); err == nil {
+			e.putFloat(f)
+		} else {
+			return err
+		}
+	case string:
+		e.putString(t)
+	case bool:
+		e.putBool(t)
+	case nil:
+		e.putNil()
+	}
+	return nil
+}
+
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int // 当前数组和 map 的嵌套层数
+}
+
+// maxDepth 是数组和 map 的最大嵌套层数, 与 encoding/json 相同. 数据来自页面, 不限制时深层嵌套会耗尽协程的栈.
+const maxDepth = 10000
+
+var (
+	errShort = errors.New("msgpack: 数据不完整")
+	errDepth = errors.New("msgpack: 嵌套层数超过 " + strconv.Itoa(maxDepth))
+)
+
+// enter 进入一层数组或 map, 超过 maxDepth 时返回错误. 返回 nil 时结束后必须调用 leave.
+func (d *decoder) enter() error {
+	if d.depth >= maxDepth {
+		return errDepth
+	}
+	d.depth++
+	return nil
+}
+
+func (d *decoder) leave() {
+	d.depth--
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) readUint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (d *decoder) readInt(n int) (int64, error) {
+	u, err := d.readUint(n)
+	switch n {
+	case 1:
+		return int64(int8(u)), err
+	case 2:
+		return int64(int16(u)), err
+	case 4:
+		return int64(int32(u)), err
+	}
+	return int64(u), err
+}
+
+// value 把一个值转换为 json 写入 out.
+func (d *decoder) value(out *bytes.Buffer) error {
+	b, err := d.next(1)
+	if err != nil {
+		return err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		out.WriteString(strconv.Itoa(int(c)))
+	case c >= 0xe0:
+		out.WriteString(strconv.Itoa(int(int8(c))))
+	case c >= 0x80 && c <= 0x8f:
+		return d.mapValue(out, int(c&0x0f))
+	case c >= 0x90 && c <= 0x9f:
+		return d.array(out, int(c&0x0f))
+	case c >= 0xa0 && c <= 0xbf:
+		return d.str(out, int(c&0x1f))
+	case c == 0xc0:
+		out.WriteString("null")
+	case c == 0xc2:
+		out.WriteString("false")
+	case c == 0xc3:
+		out.WriteString("true")
+	case c >= 0xc4 && c <= 0xc6:
+		n, err := d.readUint(1 << (c - 0xc4))
+		if err != nil {
+			return err
+		}
+		s, err := d.next(int(n))
+		if err != nil {
+			return err
+		}
+		out.WriteByte('"')
+		out.WriteString(base64.StdEncoding.EncodeToString(s))
+		out.WriteByte('"')
+	case c == 0xca:
+		u, err := d.readUint(4)
+		if err != nil {
+			return err
+		}
+		return writeFloat(out, float64(math.Float32frombits(uint32(u))), 32)
+	case c == 0xcb:
+		u, err := d.readUint(8)
+		if err != nil {
+			return err
+		}
+		return writeFloat(out, math.Float64frombits(u), 64)
+	case c >= 0xcc && c <= 0xcf:
+		u, err := d.readUint(1 << (c - 0xcc))
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatUint(u, 10))
+	case c >= 0xd0 && c <= 0xd3:
+		i, err := d.readInt(1 << (c - 0xd0))
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatInt(i, 10))
+	case c >= 0xd9 && c <= 0xdb:
+		n, err := d.readUint(1 << (c - 0xd9))
+		if err != nil {
+			return err
+		}
+		return d.str(out, int(n))
+	case c == 0xdc || c == 0xdd:
+		n, err := d.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return err
+		}
+		return d.array(out, int(n))
+	case c == 0xde || c == 0xdf:
+		n, err := d.readUint(2 << (c - 0xde))
+		if err != nil {
+			return err
+		}
+		return d.mapValue(out, int(n))
+	default:
+		return errors.New("msgpack: 不支持的类型 0x" + strconv.FormatUint(uint64(c), 16))
+	}
+	return nil
+}
+
+func writeFloat(out *bytes.Buffer, f float64, bits int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return errors.New("msgpack: 不支持的浮点数 " + strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	out.WriteString(strconv.FormatFloat(f, 'g', -1, bits))
+	return nil
+}
+
+func (d *decoder) str(out *bytes.Buffer, n int) error {
+	s, err := d.next(n)
+	if err != nil {
+		return err
+	}
+	b, _ := json.Marshal(string(s))
+	out.Write(b)
+	return nil
+}
+
+func (d *decoder) array(out *bytes.Buffer, n int) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	out.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := d.value(out); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+func (d *decoder) mapValue(out *bytes.Buffer, n int) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	out.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		// json 对象的键必须是字符串, 数字的键转换为字符串
+		var key bytes.Buffer
+		if err := d.value(&key); err != nil {
+			return err
+		}
+		k := key.Bytes()
+		if len(k) == 0 || k[0] != '"' {
+			if len(k) == 0 || k[0] == '{' || k[0] == '[' {
+				return errors.New("msgpack: map 的键必须是字符串或数字")
+			}
+			b, _ := json.Marshal(string(k))
+			k = b
+		}
+		out.Write(k)
+		out.WriteByte(':')
+		if err := d.value(out); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,296 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type inner struct {
+	Name  string            `json:"name"`
+	Tags  []string          `json:"tags,omitempty"`
+	Attrs map[string]uint16 `json:"attrs"`
+}
+
+type Embedded struct {
+	Shared int `json:"shared"`
+}
+
+type outer struct {
+	Embedded
+	ID      int64       `json:"id"`
+	Ratio   float32     `json:"ratio"`
+	Data    []byte      `json:"data"`
+	Inner   inner       `json:"inner"`
+	Ptr     *inner      `json:"ptr"`
+	List    []inner     `json:"list"`
+	Count   int         `json:"count,string"`
+	Skip    string      `json:"-"`
+	Empty   string      `json:"empty,omitempty"`
+	Any     interface{} `json:"any"`
+	private int
+}
+
+// roundTrip 把 v 编码为 MessagePack, 转换为 json 后解码到与 v 相同类型的新值.
+func roundTrip(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal(%#v): %v", v, err)
+	}
+	j, err := ToJSON(b)
+	if err != nil {
+		t.Fatalf("ToJSON(%x): %v", b, err)
+	}
+	p := reflect.New(reflect.TypeOf(v))
+	if err := json.Unmarshal(j, p.Interface()); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", j, err)
+	}
+	return p.Elem().Interface()
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"true", true},
+		{"false", false},
+		{"int8 min", int8(math.MinInt8)},
+		{"int16 min", int16(math.MinInt16)},
+		{"int32 min", int32(math.MinInt32)},
+		{"int64 min", int64(math.MinInt64)},
+		{"int64 max", int64(math.MaxInt64)},
+		{"negative fixint", -32},
+		{"below fixint", -33},
+		{"positive fixint", 127},
+		{"uint8 max", uint8(math.MaxUint8)},
+		{"uint16 max", uint16(math.MaxUint16)},
+		{"uint32 max", uint32(math.MaxUint32)},
+		{"uint64 max", uint64(math.MaxUint64)},
+		{"above 2^53", uint64(1<<53 + 1)},
+		{"float32", float32(0.1)},
+		{"float64", 0.1},
+		{"float64 large", 1e300},
+		{"float64 negative", -2.5e-10},
+		{"fixstr", "hello"},
+		{"str8", strings.Repeat("a", 32)},
+		{"str16", strings.Repeat("b", 256)},
+		{"str32", strings.Repeat("c", 65536)},
+		{"unicode", "中文   \"quote\""},
+		{"bin8", []byte{0, 1, 2, 255}},
+		{"bin16", bytes.Repeat([]byte{7}, 256)},
+		{"bin32", bytes.Repeat([]byte{9}, 65536)},
+		{"byte array", [3]byte{1, 2, 3}},
+		{"fixarray", []int{1, 2, 3}},
+		{"array16", make([]int, 16)},
+		{"array32", make([]bool, 65536)},
+		{"fixmap", map[string]int{"a": 1, "b": 2}},
+		{"map16", bigMap(16)},
+		{"map32", bigMap(65536)},
+		{"int keys", map[int]string{-1: "a", 2: "b"}},
+		{"nested", outer{
+			Embedded: Embedded{Shared: 7},
+			ID:       -1 << 60,
+			Ratio:    1.5,
+			Data:     []byte("bin"),
+			Inner:    inner{Name: "in", Tags: []string{"x"}, Attrs: map[string]uint16{"k": 65535}},
+			Ptr:      &inner{Name: "ptr"},
+			List:     []inner{{Name: "a"}, {Name: "b", Attrs: map[string]uint16{}}},
+			Count:    42,
+			Any:      "any",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := roundTrip(t, tt.v)
+			if !reflect.DeepEqual(got, tt.v) {
+				t.Fatalf("round trip = %#v, want %#v", got, tt.v)
+			}
+		})
+	}
+}
+
+func bigMap(n int) map[string]int {
+	m := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		m[strings.Repeat("k", i%7)+string(rune('a'+i%26))+hex.EncodeToString([]byte{byte(i >> 16), byte(i >> 8), byte(i)})] = i
+	}
+	return m
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"nil", nil, "c0"},
+		{"nil pointer", (*int)(nil), "c0"},
+		{"nil slice", []int(nil), "c0"},
+		{"nil map", map[string]int(nil), "c0"},
+		{"false", false, "c2"},
+		{"true", true, "c3"},
+		{"positive fixint", 127, "7f"},
+		{"uint8", 128, "cc80"},
+		{"uint16", 256, "cd0100"},
+		{"uint32", 65536, "ce00010000"},
+		{"uint64", uint64(1 << 32), "cf0000000100000000"},
+		{"negative fixint", -32, "e0"},
+		{"int8", -33, "d0df"},
+		{"int16", -129, "d1ff7f"},
+		{"int32", -32769, "d2ffff7fff"},
+		{"int64", int64(math.MinInt64), "d38000000000000000"},
+		{"float64", 1.5, "cb3ff8000000000000"},
+		{"fixstr", "a", "a161"},
+		{"str8", strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{"bin8", []byte{1}, "c40101"},
+		{"fixarray", []int{1}, "9101"},
+		{"fixmap", map[string]bool{"a": true}, "81a161c3"},
+		{"raw json", json.RawMessage(`[1,"a"]`), "9201a161"},
+		{"empty raw json", json.RawMessage(nil), "c0"},
+		{"quoted", struct {
+			N int `json:"n,string"`
+		}{5}, "81a16ea135"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(b); got != tt.want {
+				t.Fatalf("Marshal(%#v) = %s, want %s", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	for _, v := range []interface{}{math.NaN(), math.Inf(1), make(chan int), func() {}, map[[2]int]int{{1, 2}: 3}} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("Marshal(%T) returned no error", v)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"float32", "ca3fc00000", "1.5"},
+		{"uint64 max", "cfffffffffffffffff", "18446744073709551615"},
+		{"int64 min", "d38000000000000000", "-9223372036854775808"},
+		{"bin", "c403010203", `"AQID"`},
+		{"int key", "8101a161", `{"1":"a"}`},
+		{"negative key", "81ffa161", `{"-1":"a"}`},
+		{"map16", "de0001a161c0", `{"a":null}`},
+		{"array32", "dd00000002c2c3", "[false,true]"},
+		{"str16", "da0002" + "6869", `"hi"`},
+		{"str32", "db00000002" + "6869", `"hi"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.in)
+			got, err := ToJSON(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("ToJSON(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"trailing bytes", "c0c0"},
+		{"unsupported type", "c1"},
+		{"ext", "d40100"},
+		{"float NaN", "cb7ff8000000000001"},
+		{"map key array", "8190c0"},
+		{"map key map", "8180c0"},
+		{"str32 length overflow", "dbffffffff"},
+		{"array32 huge length", "ddffffffff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.in)
+			if got, err := ToJSON(b); err == nil {
+				t.Fatalf("ToJSON(%s) = %s, want error", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestToJSONTruncated(t *testing.T) {
+	b, err := Marshal(outer{
+		ID:    1 << 40,
+		Ratio: 0.25,
+		Data:  []byte("data"),
+		Inner: inner{Name: "name", Tags: []string{"a", "b"}, Attrs: map[string]uint16{"k": 300}},
+		List:  []inner{{Name: strings.Repeat("s", 40)}},
+		Any:   []interface{}{-1 << 20, 3.5, nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ToJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	// 任何前缀都不是完整的值
+	for i := 0; i < len(b); i++ {
+		if got, err := ToJSON(b[:i]); err == nil {
+			t.Fatalf("ToJSON of %d/%d bytes = %s, want error", i, len(b), got)
+		}
+	}
+}
+
+func TestToJSONDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		b := bytes.Repeat([]byte{0x91}, n)
+		return append(b, 0xc0)
+	}
+	if _, err := ToJSON(nested(maxDepth)); err != nil {
+		t.Fatalf("depth %d: %v", maxDepth, err)
+	}
+	if _, err := ToJSON(nested(maxDepth + 1)); err != errDepth {
+		t.Fatalf("depth %d: got %v, want %v", maxDepth+1, err, errDepth)
+	}
+	// map 与数组共用层数
+	b := bytes.Repeat([]byte{0x81, 0xa1, 'k'}, maxDepth)
+	b = append(b, 0x91, 0xc0)
+	if _, err := ToJSON(b); err != errDepth {
+		t.Fatalf("mixed depth: got %v, want %v", err, errDepth)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	in := `{"z":1,"a":[true,null,"s",-5,18446744073709551615,1.5,{"k":{}}]}`
+	b, err := FromJSON([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ToJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 键保持原来的顺序
+	if string(got) != in {
+		t.Fatalf("ToJSON(FromJSON(%s)) = %s", in, got)
+	}
+	if _, err := FromJSON([]byte(`{"a":`)); err == nil {
+		t.Fatal("FromJSON of truncated json returned no error")
+	}
+}
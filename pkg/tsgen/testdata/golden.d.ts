@@ -0,0 +1,59 @@
+// Code generated by xwebview tsgen. DO NOT EDIT.
+
+interface Query {
+    page: number;
+    limit?: number;
+}
+
+interface User {
+    id: number;
+    created: string;
+    name: string;
+    email?: string;
+    tags?: string[];
+    avatar: string;
+    attrs: Record<string, number>;
+    raw: any;
+    friends: User[];
+    count: string;
+    matrix: number[][];
+    "is-dashed": boolean;
+    extra: Record<string, Base>;
+    any: any;
+    inline: { X: number; };
+    labels?: Record<string, string>;
+}
+
+interface Base {
+    id: number;
+    created: string;
+}
+
+interface Window {
+    "1invalid": (arg0: Record<string, boolean>, arg1: string) => Promise<void>;
+    add: (a: number, b: number) => Promise<number>;
+    api: {
+        files: {
+            read: (arg0: string, signal?: AbortSignal) => Promise<string>;
+            watch: (arg0: string, signal?: AbortSignal) => Promise<AsyncIterableIterator<string>>;
+        };
+        getUser: (arg0: number) => Promise<User>;
+        httpGet: (arg0: string, signal?: AbortSignal) => Promise<string>;
+    };
+    app: {
+        quit: () => Promise<void>;
+        window: {
+            move: (x: number, y: number) => Promise<void>;
+        };
+    };
+    callback: (arg0: ((...args: any[]) => unknown), arg1: Array<((...args: any[]) => unknown)>) => Promise<void>;
+    fail: () => Promise<void>;
+    injected: (n: number, signal?: AbortSignal) => Promise<void>;
+    injectedNoContext: (arg0: number) => Promise<void>;
+    middleOptional: (arg0: number | null, arg1: string, arg2?: boolean) => Promise<void>;
+    noop: () => Promise<void>;
+    search: (q: Query, arg1?: number, arg2?: number) => Promise<User[]>;
+    stream: (signal?: AbortSignal) => Promise<AsyncIterableIterator<User>>;
+    variadic: (arg0: string, ...arg1: string[]) => Promise<string>;
+    withContext: (arg0: string, signal?: AbortSignal) => Promise<string>;
+}
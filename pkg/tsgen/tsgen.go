@@ -0,0 +1,458 @@
+// Package tsgen 根据绑定到 WebView 的 Go 函数生成 TypeScript 声明文件(.d.ts).
+//
+// 类型通过反射得到, 转换规则与 encoding/json 一致:
+//   - 结构体生成同名的 interface, 字段名使用 json 标签, 忽略 "-" 字段, 展开匿名嵌入的结构体
+//   - 指针字段和带有 omitempty 的字段是可选的, 末尾的指针参数也是可选的
+//   - []byte 是 string(base64), map 是 Record<string, T>, 其他 json.Marshaler 是 any
+//   - 可变参数是 ...args: T[]
+//   - 由 RegisterType 注册的类型使用注册的 ts 类型, 如 xwebview.JSFunc 是函数
+//   - 第一个参数是 context.Context 时不生成, 并在末尾添加可选的 signal?: AbortSignal
+//   - 之后由 SkipParam 注册的类型的参数也不生成, 如 *xwebview.Caller
+//   - 返回通道的函数返回 Promise<AsyncIterableIterator<T>>
+package tsgen
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/twgh/xwebview/internal/walk"
+)
+
+var (
+	contextType       = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// skipped 是由 Go 端传入, 不占用 js 端参数的参数类型.
+var skipped = map[reflect.Type]bool{}
+
+// SkipParam 注册一个由 Go 端传入, 不占用 js 端参数的参数类型, 生成声明时会跳过 context.Context 之后这种类型的参数.
+// 应该在 init 中调用.
+func SkipParam(t reflect.Type) {
+	skipped[t] = true
+}
+
+// registered 是由 RegisterType 指定的类型在 ts 中的类型.
+var registered = map[reflect.Type]string{}
+
+// RegisterType 指定类型 t 在 ts 中的类型, 用于无法通过 json 规则推断的类型, 如 xwebview.JSFunc 是 js 函数.
+// 应该在 init 中调用.
+func RegisterType(t reflect.Type, ts string) {
+	registered[t] = ts
+}
+
+// Generator 收集函数并生成 TypeScript 声明.
+type Generator struct {
+	root  *node
+	types map[reflect.Type]string // 已命名的结构体类型和它在 ts 中的名字
+	names map[string]reflect.Type // ts 中的名字和它对应的类型, 用于处理重名
+	decls []string                // 结构体生成的 interface 声明, 按发现的顺序
+}
+
+// node 是 window 上的一个对象或函数, 函数的 sig 不为空.
+type node struct {
+	children map[string]*node
+	sig      string
+	doc      string
+}
+
+// New 创建一个 Generator.
+func New() *Generator {
+	return &Generator{
+		root:  &node{children: map[string]*node{}},
+		types: map[reflect.Type]string{},
+		names: map[string]reflect.Type{},
+	}
+}
+
+// AddFunc 添加一个函数, 生成为 window.<name>.
+//
+// name: js 中的名称, 可以用点号分隔, 如 "api.getUser".
+//
+// f: Go 函数.
+//
+// paramNames: 参数名, 反射无法得到参数名, 不填时使用 arg0, arg1 ...
+func (g *Generator) AddFunc(name string, f interface{}, paramNames ...string) error {
+	t := reflect.TypeOf(f)
+	if t == nil || t.Kind() != reflect.Func {
+		return errors.New("tsgen: " + name + " is not a function")
+	}
+	return g.AddFuncType(name, t, "", paramNames...)
+}
+
+// AddFuncType 与 AddFunc 相同, 但使用函数的类型, 并可以带有注释.
+//
+// doc: 注释, 会生成为 /** doc */, 可以为空.
+func (g *Generator) AddFuncType(name string, t reflect.Type, doc string, paramNames ...string) error {
+	if name == "" {
+		return errors.New("tsgen: empty name")
+	}
+	n := g.root
+	parts := strings.Split(name, ".")
+	for _, p := range parts {
+		if n.sig != "" {
+			return errors.New("tsgen: " + name + " conflicts with a function")
+		}
+		c := n.children[p]
+		if c == nil {
+			c = &node{children: map[string]*node{}}
+			n.children[p] = c
+		}
+		n = c
+	}
+	if len(n.children) > 0 {
+		return errors.New("tsgen: " + name + " conflicts with a namespace")
+	}
+	n.sig = g.Signature(t, paramNames...)
+	n.doc = doc
+	return nil
+}
+
+// AddObject 添加 obj 的所有导出方法, 方法名转为首字母小写, 生成为 window.<namespace>.<method>, 与 WebView.BindObject 对应.
+//
+// obj 实现了 ExcludeMethods() []string 时, 返回的方法和 ExcludeMethods 本身被排除.
+// 结构体中带有 `xwebview:"name"` 标签的导出字段作为嵌套的命名空间 namespace.name 递归添加, 为 nil 的字段被忽略.
+func (g *Generator) AddObject(namespace string, obj interface{}) error {
+	err := walk.Object(namespace, obj, func(name string, method reflect.Value) error {
+		return g.AddFuncType(name, method.Type(), "")
+	})
+	if err != nil {
+		return errors.New("tsgen: " + err.Error())
+	}
+	return nil
+}
+
+// Signature 返回函数类型 t 在 ts 中的签名, 如 (arg0: string) => Promise<number>.
+func (g *Generator) Signature(t reflect.Type, paramNames ...string) string {
+	params := g.Params(t, paramNames...)
+	list := make([]string, len(params))
+	for i, p := range params {
+		list[i] = p.String()
+	}
+	return "(" + strings.Join(list, ", ") + ") => " + g.Returns(t)
+}
+
+// Param 是函数参数在 ts 中的描述.
+type Param struct {
+	Name     string
+	Type     string
+	Optional bool
+	Variadic bool
+}
+
+func (p Param) String() string {
+	if p.Variadic {
+		return "..." + p.Name + ": " + p.Type + "[]"
+	}
+	if p.Optional {
+		return p.Name + "?: " + p.Type
+	}
+	return p.Name + ": " + p.Type
+}
+
+// Params 返回函数类型 t 在 js 端的参数.
+func (g *Generator) Params(t reflect.Type, paramNames ...string) []Param {
+	var params []Param
+	start := 0
+	withContext := t.NumIn() > 0 && t.In(0) == contextType
+	if withContext {
+		start = 1
+	}
+	for start < t.NumIn() && skipped[t.In(start)] && !(t.IsVariadic() && start == t.NumIn()-1) {
+		start++
+	}
+	for i := start; i < t.NumIn(); i++ {
+		idx := i - start
+		name := "arg" + strconv.Itoa(idx)
+		if idx < len(paramNames) && paramNames[idx] != "" {
+			name = paramNames[idx]
+		}
+		in := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			params = append(params, Param{Name: name, Type: g.TypeName(in.Elem()), Variadic: true})
+			continue
+		}
+		p := Param{Name: name, Type: g.TypeName(in)}
+		if in.Kind() == reflect.Ptr {
+			p.Optional = true
+		}
+		params = append(params, p)
+	}
+	// 只有末尾连续的可选参数才能在 ts 中可选, 其他的改为可以传 null
+	trailing := true
+	for i := len(params) - 1; i >= 0; i-- {
+		if params[i].Variadic {
+			continue
+		}
+		if !params[i].Optional {
+			trailing = false
+		} else if !trailing {
+			params[i].Optional = false
+			params[i].Type += " | null"
+		}
+	}
+	if withContext && !t.IsVariadic() {
+		params = append(params, Param{Name: "signal", Type: "AbortSignal", Optional: true})
+	}
+	return params
+}
+
+// Returns 返回函数类型 t 在 js 端的返回值类型, 总是 Promise.
+func (g *Generator) Returns(t reflect.Type) string {
+	if t.NumOut() == 0 || t.Out(0).Implements(errorType) {
+		return "Promise<void>"
+	}
+	out := t.Out(0)
+	if out.Kind() == reflect.Chan {
+		return "Promise<AsyncIterableIterator<" + g.TypeName(out.Elem()) + ">>"
+	}
+	return "Promise<" + g.TypeName(out) + ">"
+}
+
+// TypeName 返回 Go 类型在 ts 中的类型, 命名的结构体会生成 interface 声明并返回它的名字.
+func (g *Generator) TypeName(t reflect.Type) string {
+	if ts, ok := registered[t]; ok {
+		return ts
+	}
+	switch t {
+	case timeType:
+		return "string"
+	case rawMessageType:
+		return "any"
+	}
+	if t.Kind() != reflect.Ptr && (t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType)) {
+		return "any"
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		if t.Kind() != reflect.Ptr {
+			return "string"
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Ptr:
+		return g.TypeName(t.Elem())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return arrayOf(g.TypeName(t.Elem()))
+	case reflect.Array:
+		return arrayOf(g.TypeName(t.Elem()))
+	case reflect.Map:
+		return "Record<string, " + g.TypeName(t.Elem()) + ">"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.inlineStruct(t)
+		}
+		return g.namedStruct(t)
+	case reflect.Chan:
+		return "AsyncIterableIterator<" + g.TypeName(t.Elem()) + ">"
+	}
+	return "any"
+}
+
+func arrayOf(elem string) string {
+	if strings.ContainsAny(elem, " |") {
+		return "Array<" + elem + ">"
+	}
+	return elem + "[]"
+}
+
+// namedStruct 为命名的结构体生成 interface 声明, 重名时在名字后面加上包名.
+func (g *Generator) namedStruct(t reflect.Type) string {
+	if name, ok := g.types[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if other, ok := g.names[name]; ok && other != t {
+		pkg := t.PkgPath()
+		if i := strings.LastIndex(pkg, "/"); i >= 0 {
+			pkg = pkg[i+1:]
+		}
+		name = exportName(pkg) + name
+		for n := 2; g.names[name] != nil; n++ {
+			name = t.Name() + strconv.Itoa(n)
+		}
+	}
+	// 先记录名字, 以支持递归的结构体
+	g.types[t] = name
+	g.names[name] = t
+	idx := len(g.decls)
+	g.decls = append(g.decls, "")
+	g.decls[idx] = "interface " + name + " " + g.structBody(t) + "\n"
+	return name
+}
+
+// structBody 生成 interface 声明的字段, 每个字段一行.
+func (g *Generator) structBody(t reflect.Type) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, f := range g.fields(t, nil, map[string]bool{}) {
+		b.WriteString("    " + f + ";\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// inlineStruct 生成匿名结构体的类型, 写在一行中, 如 { name: string; age?: number; }.
+func (g *Generator) inlineStruct(t reflect.Type) string {
+	fields := g.fields(t, nil, map[string]bool{})
+	if len(fields) == 0 {
+		return "{}"
+	}
+	return "{ " + strings.Join(fields, "; ") + "; }"
+}
+
+// fields 把结构体 t 的字段追加到 list, 如 age?: number.
+func (g *Generator) fields(t reflect.Type, list []string, seen map[string]bool) []string {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		ft := f.Type
+		// 匿名嵌入且没有指定名字的结构体, 字段会被展开
+		if f.Anonymous && name == "" {
+			et := ft
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				list = g.fields(et, list, seen)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		optional := ft.Kind() == reflect.Ptr || hasOption(opts, "omitempty")
+		typ := g.TypeName(ft)
+		if hasOption(opts, "string") {
+			typ = "string"
+		}
+		prop := propertyName(name)
+		if optional {
+			prop += "?"
+		}
+		list = append(list, prop+": "+typ)
+	}
+	return list
+}
+
+func hasOption(opts, name string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// propertyName 返回 ts 中的属性名, 不是合法标识符时加上引号.
+func propertyName(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+func exportName(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// JSMethodName 把 Go 方法名转为 js 中的驼峰命名, 如 MoveWindow -> moveWindow, HTTPGet -> httpGet, ID -> id.
+func JSMethodName(name string) string {
+	return walk.MethodName(name)
+}
+
+// Declarations 返回目前为止由 TypeName 生成的 interface 声明, 按发现的顺序.
+func (g *Generator) Declarations() []string {
+	return append([]string(nil), g.decls...)
+}
+
+// WriteTo 把声明写入 w, 生成的是全局声明, 通过合并 interface Window 给 window 添加函数.
+func (g *Generator) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by xwebview tsgen. DO NOT EDIT.\n\n")
+	for _, d := range g.decls {
+		b.WriteString(d + "\n")
+	}
+	b.WriteString("interface Window ")
+	g.writeNode(&b, g.root, "")
+	b.WriteString("\n")
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// String 返回生成的声明.
+func (g *Generator) String() string {
+	var b bytes.Buffer
+	_, _ = g.WriteTo(&b)
+	return b.String()
+}
+
+func (g *Generator) writeNode(b *bytes.Buffer, n *node, indent string) {
+	keys := make([]string, 0, len(n.children))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b.WriteString("{\n")
+	for _, k := range keys {
+		c := n.children[k]
+		if c.doc != "" {
+			b.WriteString(indent + "    /** " + strings.ReplaceAll(c.doc, "*/", "* /") + " */\n")
+		}
+		if c.sig != "" {
+			b.WriteString(indent + "    " + propertyName(k) + ": " + c.sig + ";\n")
+			continue
+		}
+		b.WriteString(indent + "    " + propertyName(k) + ": ")
+		g.writeNode(b, c, indent+"    ")
+		b.WriteString(";\n")
+	}
+	b.WriteString(indent + "}")
+}
@@ -0,0 +1,161 @@
+package tsgen
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+// caller 和 progress 模拟 xwebview 中由 Go 端传入的参数, jsFunc 模拟注册了 ts 类型的 JSFunc.
+type (
+	caller   struct{}
+	progress interface{ Report(v interface{}) }
+	jsFunc   struct{ ID string }
+)
+
+func init() {
+	SkipParam(reflect.TypeOf(&caller{}))
+	SkipParam(reflect.TypeOf((*progress)(nil)).Elem())
+	RegisterType(reflect.TypeOf(jsFunc{}), "((...args: any[]) => unknown)")
+}
+
+type Base struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type User struct {
+	Base
+	Name    string            `json:"name"`
+	Email   *string           `json:"email"`
+	Tags    []string          `json:"tags,omitempty"`
+	Avatar  []byte            `json:"avatar"`
+	Attrs   map[string]int    `json:"attrs"`
+	Raw     json.RawMessage   `json:"raw"`
+	Friends []*User           `json:"friends"`
+	Count   int               `json:"count,string"`
+	Secret  string            `json:"-"`
+	Matrix  [2][2]float64     `json:"matrix"`
+	Dashed  bool              `json:"is-dashed"`
+	Extra   map[string]*Base  `json:"extra"`
+	Any     interface{}       `json:"any"`
+	Inline  struct{ X int }   `json:"inline"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	private int
+}
+
+type Query struct {
+	Page  int  `json:"page"`
+	Limit *int `json:"limit"`
+}
+
+// api 的方法通过 AddObject 添加.
+type api struct {
+	Files *files `xwebview:"files"`
+	Nil   *files `xwebview:"nil"`
+	Skip  *files `xwebview:"-"`
+}
+
+func (api) GetUser(id int64) (*User, error)                         { return nil, nil }
+func (api) HTTPGet(ctx context.Context, url string) (string, error) { return "", nil }
+func (api) Close() error                                            { return nil }
+func (api) ExcludeMethods() []string                                { return []string{"Close"} }
+func (api) unexported()                                             {}
+
+type files struct{}
+
+func (*files) Read(ctx context.Context, c *caller, p progress, path string) ([]byte, error) {
+	return nil, nil
+}
+
+func (*files) Watch(ctx context.Context, dir string) (<-chan string, error) { return nil, nil }
+
+func TestGolden(t *testing.T) {
+	g := New()
+	funcs := []struct {
+		name  string
+		f     interface{}
+		names []string
+	}{
+		{"add", func(a, b int) int { return a + b }, []string{"a", "b"}},
+		{"noop", func() {}, nil},
+		{"fail", func() error { return nil }, nil},
+		{"search", func(q Query, limit *int, offset *int) ([]User, error) { return nil, nil }, []string{"q"}},
+		{"middleOptional", func(a *int, b string, c *bool) {}, nil},
+		{"withContext", func(ctx context.Context, name string) (string, error) { return "", nil }, nil},
+		{"injected", func(ctx context.Context, c *caller, p progress, n int) {}, []string{"n"}},
+		{"injectedNoContext", func(c *caller, n int) {}, nil},
+		{"variadic", func(ctx context.Context, prefix string, parts ...string) string { return "" }, nil},
+		{"callback", func(f jsFunc, fs []jsFunc) {}, nil},
+		{"stream", func(ctx context.Context) (<-chan *User, error) { return nil, nil }, nil},
+		{"app.window.move", func(x, y int) {}, []string{"x", "y"}},
+		{"app.quit", func() {}, nil},
+		{"1invalid", func(m map[int]bool, t time.Time) {}, nil},
+	}
+	for _, f := range funcs {
+		if err := g.AddFunc(f.name, f.f, f.names...); err != nil {
+			t.Fatalf("AddFunc(%s): %v", f.name, err)
+		}
+	}
+	if err := g.AddObject("api", api{Files: &files{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := g.String()
+	path := filepath.Join("testdata", "golden.d.ts")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("generated declarations differ from %s, run go test -update and review the git diff:\n%s", path, got)
+	}
+}
+
+func TestAddConflicts(t *testing.T) {
+	g := New()
+	if err := g.AddFunc("a.b", func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddFunc("a", func() {}); err == nil {
+		t.Error("function over a namespace: no error")
+	}
+	if err := g.AddFunc("a.b.c", func() {}); err == nil {
+		t.Error("namespace under a function: no error")
+	}
+	if err := g.AddFunc("x", 1); err == nil {
+		t.Error("non-function: no error")
+	}
+	if err := g.AddObject("o", nil); err == nil {
+		t.Error("nil object: no error")
+	}
+}
+
+func TestJSMethodName(t *testing.T) {
+	tests := map[string]string{
+		"MoveWindow": "moveWindow",
+		"HTTPGet":    "httpGet",
+		"ID":         "id",
+		"A":          "a",
+		"GetURLs":    "getURLs",
+		"already":    "already",
+		"":           "",
+	}
+	for in, want := range tests {
+		if got := JSMethodName(in); got != want {
+			t.Errorf("JSMethodName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
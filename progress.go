@@ -0,0 +1,131 @@
+package xwebview
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/twgh/xwebview/pkg/tsgen"
+)
+
+// progressInterval 是向 js 端发送进度的最小间隔, 间隔内多次报告的进度只发送最后一个.
+const progressInterval = 100 * time.Millisecond
+
+// Progress 用于绑定的函数向 js 端报告执行进度.
+//
+// 函数的参数中可以声明 Progress, 它由 xwebview 传入, 不占用 js 端的参数, 与 *Caller 一样必须在 context.Context 之后, 其他参数之前.
+// js 端通过调用返回的 Promise 的 onProgress 方法接收进度:
+//
+//	calculateMD5(path).onProgress(p => console.log(p.percent)).then(...)
+//
+// 进度会被节流, 每 100 毫秒最多发送一次, 间隔内只发送最后一次报告的值, 函数返回前报告的最后一个值总会在结果之前送达.
+// 通过 JSON-RPC 的调用没有 onProgress, 报告的进度会被丢弃.
+type Progress interface {
+	// Report 报告进度, v 会被转换为 json. 可以在任意线程调用, 函数返回后调用无效.
+	Report(v interface{})
+}
+
+var progressType = reflect.TypeOf((*Progress)(nil)).Elem()
+
+func init() {
+	tsgen.SkipParam(progressType)
+}
+
+// progress 是 Progress 的实现, w 为 nil 时丢弃所有进度.
+type progress struct {
+	w    *WebView
+	page uint64
+	id   int
+
+	mu      sync.Mutex
+	value   interface{}
+	pending bool
+	last    time.Time
+	timer   *time.Timer
+	closed  bool
+}
+
+// newProgress 创建当前页面中 id 对应的调用的进度报告器.
+func (w *WebView) newProgress(page uint64, id int) *progress {
+	return &progress{w: w, page: page, id: id}
+}
+
+// Report 实现 Progress. 只记录最新的值, 由定时器在间隔到达后发送.
+func (p *progress) Report(v interface{}) {
+	if p.w == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.value, p.pending = v, true
+	if p.timer != nil {
+		return
+	}
+	wait := progressInterval - time.Since(p.last)
+	if wait < 0 {
+		wait = 0
+	}
+	p.timer = time.AfterFunc(wait, p.flush)
+}
+
+// take 取出尚未发送的值.
+func (p *progress) take() (interface{}, bool) {
+	v, ok := p.value, p.pending
+	p.value, p.pending = nil, false
+	p.last = time.Now()
+	return v, ok
+}
+
+// sendPending 取出并发送尚未发送的值. 必须在UI线程执行.
+//
+// 取出和发送都在UI线程, 所以先取出的值总是先送达, close 发送的最后一个值不会被之前的 flush 越过.
+func (p *progress) sendPending() {
+	p.mu.Lock()
+	v, ok := p.take()
+	p.mu.Unlock()
+	if ok {
+		p.send(v)
+	}
+}
+
+// flush 在定时器中让UI线程发送最新的值.
+func (p *progress) flush() {
+	p.mu.Lock()
+	p.timer = nil
+	closed := p.closed
+	p.mu.Unlock()
+	if !closed {
+		p.w.Dispatch(p.sendPending)
+	}
+}
+
+// close 在函数返回后停止报告, 并发送尚未发送的最后一个值. 必须在返回结果之前调用.
+func (p *progress) close() {
+	if p == nil || p.w == nil {
+		return
+	}
+	p.mu.Lock()
+	p.closed = true
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+	p.w.ui(p.sendPending)
+}
+
+// send 把进度发送给 js 端的 onProgress. 必须在UI线程执行.
+func (p *progress) send(v interface{}) {
+	if !p.w.isCurrentPage(p.page) {
+		return
+	}
+	js, err := p.w.jsValue(v)
+	if err != nil {
+		return
+	}
+	p.w.Eval("window._rpc.progress(" + strconv.Itoa(p.id) + ", " + js + ");")
+}
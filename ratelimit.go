@@ -0,0 +1,148 @@
+package xwebview
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrCodeRateLimited 是调用超出了 RateLimit 的限制, GoError 的 data 是 RateLimitData.
+const ErrCodeRateLimited = "RATE_LIMITED"
+
+// RateLimit 限制 js 端调用绑定的函数的频率和并发数, 零值表示不限制.
+//
+// 可以通过 BindOption.RateLimit 对单个函数设置, 也可以通过 XcWebViewOption.RateLimit 对整个 WebView 的所有调用设置, 两者同时生效.
+// 超出限制的调用不会执行, js 端的 Promise 被拒绝为错误码为 ErrCodeRateLimited 的 GoError.
+type RateLimit struct {
+	// Rate 是每秒允许的调用次数(令牌桶的填充速度), 为0时不限制频率.
+	Rate float64
+	// Burst 是令牌桶的容量, 即短时间内最多连续调用的次数, 为0时等于 Rate 向上取整(至少为1).
+	Burst int
+	// MaxInFlight 是同时执行中的调用数量, 包括在协程池中等待的调用和未结束的流, 为0时不限制.
+	MaxInFlight int
+}
+
+// RateLimitData 是 ErrCodeRateLimited 错误的附加数据, 在 js 端为 GoError 的 data 属性.
+type RateLimitData struct {
+	// Scope 是触发限制的范围, "binding" 为函数的限制, "webview" 为 WebView 的限制.
+	Scope string `json:"scope"`
+	// Reason 是触发的限制, "rate" 为调用频率, "inflight" 为并发数.
+	Reason string `json:"reason"`
+	// RetryAfter 是建议的重试间隔, 单位毫秒, 超出并发数时为0.
+	RetryAfter int64 `json:"retryAfter"`
+}
+
+// RateLimitStats 是限流的统计数据, 用于诊断.
+type RateLimitStats struct {
+	// Accepted 是通过限制的调用次数.
+	Accepted uint64
+	// RejectedRate 是因超出频率被拒绝的次数.
+	RejectedRate uint64
+	// RejectedInFlight 是因超出并发数被拒绝的次数.
+	RejectedInFlight uint64
+	// InFlight 是当前执行中的调用数量.
+	InFlight int
+	// PeakInFlight 是执行中的调用数量的最大值.
+	PeakInFlight int
+}
+
+// limiter 是令牌桶和并发计数, 同时记录统计数据.
+type limiter struct {
+	mu     sync.Mutex
+	scope  string
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+	stats  RateLimitStats
+}
+
+// newLimiter 创建限流器, scope 是 RateLimitData.Scope.
+func newLimiter(scope string, l RateLimit) *limiter {
+	if l.Rate > 0 && l.Burst <= 0 {
+		l.Burst = int(math.Ceil(l.Rate))
+	}
+	return &limiter{scope: scope, limit: l, tokens: float64(l.Burst), last: time.Now()}
+}
+
+// acquire 占用一个令牌和一个并发数, 超出限制时返回 ErrCodeRateLimited 的错误. 成功时调用结束后必须执行 release.
+func (l *limiter) acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit.MaxInFlight > 0 && l.stats.InFlight >= l.limit.MaxInFlight {
+		l.stats.RejectedInFlight++
+		return NewError(ErrCodeRateLimited, "并发调用过多", RateLimitData{Scope: l.scope, Reason: "inflight"})
+	}
+	if l.limit.Rate > 0 {
+		now := time.Now()
+		l.tokens = math.Min(float64(l.limit.Burst), l.tokens+now.Sub(l.last).Seconds()*l.limit.Rate)
+		l.last = now
+		if l.tokens < 1 {
+			l.stats.RejectedRate++
+			wait := time.Duration((1 - l.tokens) / l.limit.Rate * float64(time.Second))
+			return NewError(ErrCodeRateLimited, "调用过于频繁", RateLimitData{Scope: l.scope, Reason: "rate", RetryAfter: int64(math.Ceil(float64(wait) / float64(time.Millisecond)))})
+		}
+		l.tokens--
+	}
+	l.stats.Accepted++
+	l.stats.InFlight++
+	if l.stats.InFlight > l.stats.PeakInFlight {
+		l.stats.PeakInFlight = l.stats.InFlight
+	}
+	return nil
+}
+
+// release 释放 acquire 占用的并发数.
+func (l *limiter) release() {
+	l.mu.Lock()
+	l.stats.InFlight--
+	l.mu.Unlock()
+}
+
+// undo 撤销一次成功的 acquire, 用于另一个范围的限制拒绝了这次调用.
+func (l *limiter) undo() {
+	l.mu.Lock()
+	l.stats.Accepted--
+	l.stats.InFlight--
+	if l.limit.Rate > 0 {
+		l.tokens = math.Min(float64(l.limit.Burst), l.tokens+1)
+	}
+	l.mu.Unlock()
+}
+
+func (l *limiter) snapshot() RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// admit 检查函数和 WebView 的限制, 通过时返回调用结束后必须执行的 release.
+func (w *WebView) admit(b *binding) (release func(), err error) {
+	if err := b.limiter.acquire(); err != nil {
+		return nil, err
+	}
+	if err := w.limiter.acquire(); err != nil {
+		b.limiter.undo()
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.limiter.release()
+			b.limiter.release()
+		})
+	}, nil
+}
+
+// GetRateLimitStats 返回限流的统计数据.
+//
+// name: 绑定时的名称, 不填时返回整个 WebView 的统计数据. 函数不存在时返回 ErrBindingNotFound.
+func (w *WebView) GetRateLimitStats(name ...string) (RateLimitStats, error) {
+	if len(name) == 0 {
+		return w.limiter.snapshot(), nil
+	}
+	b, ok := w.getBinding(name[0])
+	if !ok {
+		return RateLimitStats{}, ErrBindingNotFound
+	}
+	return b.limiter.snapshot(), nil
+}
@@ -0,0 +1,916 @@
+// xwebview 注入到页面的运行时, 在 New 中通过 Init 注入, 先于所有绑定的函数执行.
+(function () {
+    var RPC = window._rpc = (window._rpc || {nextSeq: 1});
+    if (RPC.bind) {
+        return;
+    }
+
+    // 按点号分隔的名称找到 window 上的对象和属性名, create 为 true 时创建中间对象.
+    function lookup(name, create) {
+        var parts = name.split('.');
+        var obj = window;
+        for (var i = 0; i < parts.length - 1; i++) {
+            if (obj[parts[i]] === undefined || obj[parts[i]] === null) {
+                if (!create) {
+                    return null;
+                }
+                obj[parts[i]] = {};
+            }
+            obj = obj[parts[i]];
+        }
+        return {obj: obj, key: parts[parts.length - 1]};
+    }
+
+    // GoError 是 Go 端返回错误时 Promise 的拒绝原因, 带有 code, message 和 data 属性.
+    class GoError extends Error {
+        constructor(e) {
+            super(e.message);
+            this.name = 'GoError';
+            this.code = e.code;
+            this.data = e.data;
+        }
+    }
+    RPC.GoError = GoError;
+
+    // 取消调用时 Promise 的拒绝原因.
+    function abortReason(signal) {
+        if (signal.reason !== undefined) {
+            return signal.reason;
+        }
+        return new DOMException('The operation was aborted.', 'AbortError');
+    }
+
+    // funcs 是作为参数传给 Go 的 js 函数, Go 端通过 JSFunc 调用. ID 带有页面的随机前缀, 旧页面的 JSFunc 不会调用到新页面的函数.
+    RPC.funcs = {};
+    var funcPrefix = Math.random().toString(36).slice(2) + '.';
+    var nextFunc = 1;
+
+    // encodeFunc 是编码时的 replacer, 把函数注册到 funcs 中, 替换为 {__jsfunc: id}.
+    function encodeFunc(key, value) {
+        if (typeof value !== 'function') {
+            return value;
+        }
+        var id = funcPrefix + nextFunc++;
+        RPC.funcs[id] = value;
+        return {__jsfunc: id};
+    }
+
+    // codecs 是与 Go 端的 Codec 对应的编码方式, 由 setCodec 选择, 默认是 json.
+    // encode(value, replacer) 把值编码为字符串, replacer 与 JSON.stringify 的相同, decode(string) 解码 Go 端发来的字符串.
+    RPC.codecs = {};
+    RPC.codecs.json = {
+        encode: function (value, replacer) {
+            return JSON.stringify(value, replacer);
+        },
+        decode: function (s) {
+            return JSON.parse(s);
+        },
+    };
+
+    // json-bigint 中超出安全整数范围的整数是 {$bigint: "N"}, 在 js 中是 BigInt.
+    RPC.codecs['json-bigint'] = {
+        encode: function (value, replacer) {
+            return JSON.stringify(value, function (key, v) {
+                if (typeof v === 'bigint') {
+                    return {$bigint: v.toString()};
+                }
+                return replacer ? replacer.call(this, key, v) : v;
+            });
+        },
+        decode: function (s) {
+            return JSON.parse(s, function (key, v) {
+                if (v && typeof v === 'object' && typeof v.$bigint === 'string' && Object.keys(v).length === 1) {
+                    return BigInt(v.$bigint);
+                }
+                return v;
+            });
+        },
+    };
+
+    // msgpack 与 Go 端的 pkg/msgpack 对应, 以 base64 传输. Uint8Array 是 bin, 超出安全整数范围的整数是 BigInt.
+    RPC.codecs.msgpack = (function () {
+        var utf8Encoder = new TextEncoder();
+        var utf8Decoder = new TextDecoder();
+
+        function pack(value, replacer) {
+            var buf = new Uint8Array(256);
+            var view = new DataView(buf.buffer);
+            var pos = 0;
+
+            function ensure(n) {
+                if (pos + n <= buf.length) {
+                    return;
+                }
+                var size = buf.length * 2;
+                while (size < pos + n) {
+                    size *= 2;
+                }
+                var b = new Uint8Array(size);
+                b.set(buf);
+                buf = b;
+                view = new DataView(buf.buffer);
+            }
+
+            function put(c, n, setter, x) {
+                ensure(1 + n);
+                buf[pos++] = c;
+                if (setter) {
+                    view[setter](pos, x);
+                    pos += n;
+                }
+            }
+
+            function header(n, fix, fixMax, c8, c16, c32) {
+                if (n <= fixMax) {
+                    put(fix | n, 0);
+                } else if (c8 && n < 0x100) {
+                    put(c8, 1, 'setUint8', n);
+                } else if (n < 0x10000) {
+                    put(c16, 2, 'setUint16', n);
+                } else {
+                    put(c32, 4, 'setUint32', n);
+                }
+            }
+
+            function integer(x) {
+                if (x >= 0) {
+                    if (x < 0x80) {
+                        put(x, 0);
+                    } else if (x < 0x100) {
+                        put(0xcc, 1, 'setUint8', x);
+                    } else if (x < 0x10000) {
+                        put(0xcd, 2, 'setUint16', x);
+                    } else if (x < 0x100000000) {
+                        put(0xce, 4, 'setUint32', x);
+                    } else if (BigInt(x) < 0x10000000000000000n) {
+                        put(0xcf, 8, 'setBigUint64', BigInt(x));
+                    } else {
+                        throw new RangeError('msgpack: integer out of range');
+                    }
+                } else if (x >= -32) {
+                    put(x & 0xff, 0);
+                } else if (x >= -0x80) {
+                    put(0xd0, 1, 'setInt8', x);
+                } else if (x >= -0x8000) {
+                    put(0xd1, 2, 'setInt16', x);
+                } else if (x >= -0x80000000) {
+                    put(0xd2, 4, 'setInt32', x);
+                } else if (BigInt(x) >= -0x8000000000000000n) {
+                    put(0xd3, 8, 'setBigInt64', BigInt(x));
+                } else {
+                    throw new RangeError('msgpack: integer out of range');
+                }
+            }
+
+            function bytes(b) {
+                header(b.length, 0, -1, 0xc4, 0xc5, 0xc6);
+                ensure(b.length);
+                buf.set(b, pos);
+                pos += b.length;
+            }
+
+            // prepare 与 JSON.stringify 一样调用 toJSON 和 replacer.
+            function prepare(holder, key) {
+                var v = holder[key];
+                if (v && typeof v.toJSON === 'function') {
+                    v = v.toJSON(key);
+                }
+                return replacer ? replacer.call(holder, key, v) : v;
+            }
+
+            function skip(v) {
+                return v === undefined || typeof v === 'function' || typeof v === 'symbol';
+            }
+
+            function write(v) {
+                if (v === null || skip(v)) {
+                    put(0xc0, 0);
+                } else if (typeof v === 'boolean') {
+                    put(v ? 0xc3 : 0xc2, 0);
+                } else if (typeof v === 'number') {
+                    if (Number.isSafeInteger(v) && !Object.is(v, -0)) {
+                        integer(v);
+                    } else if (isFinite(v)) {
+                        put(0xcb, 8, 'setFloat64', v);
+                    } else {
+                        put(0xc0, 0);
+                    }
+                } else if (typeof v === 'bigint') {
+                    // 小的 BigInt 按 Number 编码, 只有超出安全整数的值才使用64位的编码
+                    if (v >= BigInt(Number.MIN_SAFE_INTEGER) && v <= BigInt(Number.MAX_SAFE_INTEGER)) {
+                        integer(Number(v));
+                    } else {
+                        integer(v);
+                    }
+                } else if (typeof v === 'string') {
+                    var s = utf8Encoder.encode(v);
+                    header(s.length, 0xa0, 31, 0xd9, 0xda, 0xdb);
+                    ensure(s.length);
+                    buf.set(s, pos);
+                    pos += s.length;
+                } else if (v instanceof ArrayBuffer) {
+                    bytes(new Uint8Array(v));
+                } else if (ArrayBuffer.isView(v)) {
+                    bytes(new Uint8Array(v.buffer, v.byteOffset, v.byteLength));
+                } else if (Array.isArray(v)) {
+                    header(v.length, 0x90, 15, 0, 0xdc, 0xdd);
+                    for (var i = 0; i < v.length; i++) {
+                        write(prepare(v, String(i)));
+                    }
+                } else {
+                    var keys = [];
+                    var values = [];
+                    Object.keys(v).forEach(function (k) {
+                        var x = prepare(v, k);
+                        if (!skip(x)) {
+                            keys.push(k);
+                            values.push(x);
+                        }
+                    });
+                    header(keys.length, 0x80, 15, 0, 0xde, 0xdf);
+                    for (var j = 0; j < keys.length; j++) {
+                        write(keys[j]);
+                        write(values[j]);
+                    }
+                }
+            }
+
+            write(prepare({'': value}, ''));
+            return buf.subarray(0, pos);
+        }
+
+        function unpack(b) {
+            var view = new DataView(b.buffer, b.byteOffset, b.byteLength);
+            var pos = 0;
+
+            function get(getter, n) {
+                var v = view[getter](pos);
+                pos += n;
+                return v;
+            }
+
+            function integer(x) {
+                if (x <= Number.MAX_SAFE_INTEGER && x >= -Number.MAX_SAFE_INTEGER) {
+                    return Number(x);
+                }
+                return x;
+            }
+
+            function str(n) {
+                var s = utf8Decoder.decode(b.subarray(pos, pos + n));
+                pos += n;
+                return s;
+            }
+
+            function bin(n) {
+                var r = b.slice(pos, pos + n);
+                pos += n;
+                return r;
+            }
+
+            function array(n) {
+                var a = new Array(n);
+                for (var i = 0; i < n; i++) {
+                    a[i] = read();
+                }
+                return a;
+            }
+
+            function map(n) {
+                var o = {};
+                for (var i = 0; i < n; i++) {
+                    var k = read();
+                    o[k] = read();
+                }
+                return o;
+            }
+
+            function read() {
+                if (pos >= b.length) {
+                    throw new RangeError('msgpack: unexpected end of data');
+                }
+                var c = b[pos++];
+                if (c <= 0x7f) {
+                    return c;
+                }
+                if (c >= 0xe0) {
+                    return c - 0x100;
+                }
+                if (c <= 0x8f) {
+                    return map(c & 0x0f);
+                }
+                if (c <= 0x9f) {
+                    return array(c & 0x0f);
+                }
+                if (c <= 0xbf) {
+                    return str(c & 0x1f);
+                }
+                switch (c) {
+                case 0xc0:
+                    return null;
+                case 0xc2:
+                    return false;
+                case 0xc3:
+                    return true;
+                case 0xc4:
+                    return bin(get('getUint8', 1));
+                case 0xc5:
+                    return bin(get('getUint16', 2));
+                case 0xc6:
+                    return bin(get('getUint32', 4));
+                case 0xca:
+                    return get('getFloat32', 4);
+                case 0xcb:
+                    return get('getFloat64', 8);
+                case 0xcc:
+                    return get('getUint8', 1);
+                case 0xcd:
+                    return get('getUint16', 2);
+                case 0xce:
+                    return get('getUint32', 4);
+                case 0xcf:
+                    return integer(get('getBigUint64', 8));
+                case 0xd0:
+                    return get('getInt8', 1);
+                case 0xd1:
+                    return get('getInt16', 2);
+                case 0xd2:
+                    return get('getInt32', 4);
+                case 0xd3:
+                    return integer(get('getBigInt64', 8));
+                case 0xd9:
+                    return str(get('getUint8', 1));
+                case 0xda:
+                    return str(get('getUint16', 2));
+                case 0xdb:
+                    return str(get('getUint32', 4));
+                case 0xdc:
+                    return array(get('getUint16', 2));
+                case 0xdd:
+                    return array(get('getUint32', 4));
+                case 0xde:
+                    return map(get('getUint16', 2));
+                case 0xdf:
+                    return map(get('getUint32', 4));
+                }
+                throw new TypeError('msgpack: unsupported type 0x' + c.toString(16));
+            }
+
+            return read();
+        }
+
+        return {
+            encode: function (value, replacer) {
+                var b = pack(value, replacer);
+                var s = '';
+                for (var i = 0; i < b.length; i += 0x8000) {
+                    s += String.fromCharCode.apply(null, b.subarray(i, i + 0x8000));
+                }
+                return btoa(s);
+            },
+            decode: function (s) {
+                var bin = atob(s);
+                var b = new Uint8Array(bin.length);
+                for (var i = 0; i < bin.length; i++) {
+                    b[i] = bin.charCodeAt(i);
+                }
+                return unpack(b);
+            },
+        };
+    })();
+
+    RPC.codec = RPC.codecs.json;
+
+    // setCodec 选择编码方式, 由 Go 端在注入运行时后调用.
+    RPC.setCodec = function (name) {
+        if (!RPC.codecs[name]) {
+            throw new Error('xwebview: unknown codec ' + name);
+        }
+        RPC.codec = RPC.codecs[name];
+    };
+
+    // decode 解码 Go 端发来的值.
+    RPC.decode = function (s) {
+        return RPC.codec.decode(s);
+    };
+
+    // state 是与 Go 端握手的状态: connecting, ready 或 failed. 握手完成之前发送的消息放入 outbox,
+    // 收到的需要解码的消息放入 inbox, 握手后用协商的编码方式处理.
+    RPC.state = 'connecting';
+    var outbox = [];
+    var inbox = [];
+
+    function send(m) {
+        if (RPC.state === 'connecting') {
+            outbox.push(m);
+            return;
+        }
+        if (RPC.state === 'ready') {
+            window.external.invoke(RPC.codec.encode(m, encodeFunc));
+        }
+    }
+
+    // call 调用绑定的 Go 函数, 最后一个参数是 AbortSignal 时, 可以用它取消调用.
+    RPC.call = function (method, args) {
+        if (RPC.failure) {
+            return Promise.reject(RPC.failure);
+        }
+        var params = Array.prototype.slice.call(args);
+        var signal = null;
+        if (typeof AbortSignal !== 'undefined' && params[params.length - 1] instanceof AbortSignal) {
+            signal = params.pop();
+            if (signal.aborted) {
+                return Promise.reject(abortReason(signal));
+            }
+        }
+        var seq = RPC.nextSeq++;
+        var promise = new Promise(function (resolve, reject) {
+            RPC[seq] = {
+                resolve: resolve,
+                reject: reject,
+            };
+        });
+        send({
+            id: seq,
+            method: method,
+            params: params,
+        });
+        // onProgress 接收 Go 端通过 Progress 报告的进度, 返回 Promise 本身, 可以继续链式调用.
+        promise.onProgress = function (fn) {
+            if (RPC[seq]) {
+                RPC[seq].progress = fn;
+            }
+            return promise;
+        };
+        if (signal) {
+            var onAbort = function () {
+                var p = RPC[seq];
+                var s = RPC.streams[seq];
+                if (!p && !s) {
+                    return;
+                }
+                send({cancel: seq});
+                if (p) {
+                    delete RPC[seq];
+                    p.reject(abortReason(signal));
+                }
+                if (s) {
+                    endStream(seq, abortReason(signal));
+                }
+            };
+            signal.addEventListener('abort', onAbort);
+            RPC[seq].cleanup = function () {
+                signal.removeEventListener('abort', onAbort);
+            };
+        }
+        return promise;
+    };
+
+    // settle 结束 seq 对应的调用, 由 Go 端调用.
+    function settle(seq, ok, value) {
+        var p = RPC[seq];
+        if (!p) {
+            return;
+        }
+        delete RPC[seq];
+        if (p.cleanup) {
+            p.cleanup();
+        }
+        if (ok) {
+            p.resolve(value);
+        } else {
+            p.reject(value);
+        }
+    }
+
+    RPC.resolve = function (seq, value) {
+        settle(seq, true, value);
+    };
+
+    RPC.reject = function (seq, e) {
+        settle(seq, false, new GoError(e));
+    };
+
+    // progress 把进度交给 seq 对应的调用的 onProgress, 由 Go 端调用.
+    RPC.progress = function (seq, value) {
+        var p = RPC[seq];
+        if (!p || !p.progress) {
+            return;
+        }
+        try {
+            p.progress(value);
+        } catch (e) {
+            console.error(e);
+        }
+    };
+
+    // handlers 按 Go 端发来的消息的 type 分发. 编码方式是 json 时消息是对象, 否则是需要解码的字符串.
+    RPC.handlers = {};
+    function receive(m) {
+        if (typeof m === 'string') {
+            // 握手完成之前还不知道编码方式
+            if (RPC.state === 'connecting') {
+                inbox.push(m);
+                return;
+            }
+            if (RPC.codec !== RPC.codecs.json) {
+                try {
+                    m = RPC.codec.decode(m);
+                } catch (err) {
+                    return;
+                }
+            }
+        }
+        if (!m || typeof m !== 'object' || !RPC.handlers[m.type]) {
+            return;
+        }
+        RPC.handlers[m.type](m);
+    }
+
+    if (window.chrome && window.chrome.webview) {
+        window.chrome.webview.addEventListener('message', function (e) {
+            receive(e.data);
+        });
+    }
+
+    // window.xwebview 是给页面使用的事件总线.
+    var xw = window.xwebview = (window.xwebview || {});
+    var listeners = {};
+    xw.GoError = GoError;
+    // xw.bindings() 返回已绑定的函数的描述, 由 Go 端作为内置函数绑定, 见 WebView.Bindings.
+
+    // on 监听 Go 端通过 WebView.Emit 发送的事件, 返回取消监听的函数.
+    xw.on = function (event, fn) {
+        (listeners[event] = listeners[event] || []).push(fn);
+        return function () {
+            xw.off(event, fn);
+        };
+    };
+
+    // off 取消监听, 不传 fn 时取消该事件的所有监听.
+    xw.off = function (event, fn) {
+        var fns = listeners[event];
+        if (!fns) {
+            return;
+        }
+        if (fn === undefined) {
+            delete listeners[event];
+            return;
+        }
+        var i = fns.indexOf(fn);
+        if (i >= 0) {
+            fns.splice(i, 1);
+        }
+    };
+
+    // once 只监听一次.
+    xw.once = function (event, fn) {
+        var off = xw.on(event, function (data) {
+            off();
+            fn(data);
+        });
+        return off;
+    };
+
+    // emit 向 Go 端发送事件, 由 WebView.On 注册的函数处理.
+    xw.emit = function (event, data) {
+        send({
+            event: event,
+            data: data === undefined ? null : data,
+        });
+    };
+
+    RPC.handlers.event = function (m) {
+        var fns = (listeners[m.event] || []).slice();
+        for (var i = 0; i < fns.length; i++) {
+            try {
+                fns[i](m.data);
+            } catch (e) {
+                console.error(e);
+            }
+        }
+    };
+
+    // streams 是 Go 端返回通道的调用, 在js中是异步迭代器.
+    RPC.streams = {};
+
+    // endStream 结束流, err 为空表示正常结束.
+    function endStream(seq, err) {
+        var s = RPC.streams[seq];
+        if (!s) {
+            return;
+        }
+        delete RPC.streams[seq];
+        if (s.cleanup) {
+            s.cleanup();
+        }
+        s.done = true;
+        s.error = err || null;
+        var waiters = s.waiters.splice(0);
+        for (var i = 0; i < waiters.length; i++) {
+            if (err) {
+                waiters[i].reject(err);
+            } else {
+                waiters[i].resolve({value: undefined, done: true});
+            }
+        }
+    }
+
+    // consumed 在元素被消费后调用, 消费了一半窗口后向 Go 端发放额度.
+    function consumed(seq, s) {
+        s.taken++;
+        if (s.taken * 2 >= s.window && RPC.streams[seq]) {
+            send({stream: seq, credit: s.taken});
+            s.taken = 0;
+        }
+    }
+
+    // resolveStream 把 seq 对应的 Promise 解决为异步迭代器, 在收到 Go 端的 {type: 'stream', open: window} 时调用.
+    RPC.resolveStream = function (seq, windowSize) {
+        var p = RPC[seq];
+        if (!p) {
+            return;
+        }
+        delete RPC[seq];
+        var s = {queue: [], waiters: [], done: false, error: null, taken: 0, window: windowSize, cleanup: p.cleanup};
+        RPC.streams[seq] = s;
+        var it = {
+            next: function () {
+                if (s.queue.length > 0) {
+                    var value = s.queue.shift();
+                    consumed(seq, s);
+                    return Promise.resolve({value: value, done: false});
+                }
+                if (s.error) {
+                    return Promise.reject(s.error);
+                }
+                if (s.done) {
+                    return Promise.resolve({value: undefined, done: true});
+                }
+                return new Promise(function (resolve, reject) {
+                    s.waiters.push({resolve: resolve, reject: reject});
+                });
+            },
+            // break 或 return 时通知 Go 端停止
+            return: function (value) {
+                if (RPC.streams[seq]) {
+                    send({cancel: seq});
+                    endStream(seq);
+                }
+                s.queue = [];
+                return Promise.resolve({value: value, done: true});
+            },
+        };
+        it[Symbol.asyncIterator] = function () {
+            return it;
+        };
+        p.resolve(it);
+    };
+
+    RPC.handlers.stream = function (m) {
+        if (m.open) {
+            RPC.resolveStream(m.id, m.open);
+            return;
+        }
+        var s = RPC.streams[m.id];
+        if (!s) {
+            return;
+        }
+        if (m.error) {
+            endStream(m.id, new GoError(m.error));
+        } else if (m.done) {
+            // 队列中剩余的元素仍然可以被消费
+            endStream(m.id);
+        } else if (s.waiters.length > 0) {
+            s.waiters.shift().resolve({value: m.value, done: false});
+            consumed(m.id, s);
+        } else {
+            s.queue.push(m.value);
+        }
+    };
+
+    // xwebview.rpc 使用 JSON-RPC 2.0 协议调用绑定的 Go 函数, params 可以是数组或对象.
+    var rpcPending = {};
+    var rpcNextId = 1;
+
+    function rpcRequest(method, params, notify) {
+        var req = {jsonrpc: '2.0', method: method};
+        if (params !== undefined) {
+            req.params = params;
+        }
+        if (notify) {
+            return {req: req};
+        }
+        req.id = rpcNextId++;
+        var promise = new Promise(function (resolve, reject) {
+            rpcPending[req.id] = {resolve: resolve, reject: reject};
+        });
+        return {req: req, promise: promise};
+    }
+
+    function rpcError(e) {
+        var d = e.data || {};
+        var err = new GoError({code: d.code || e.code, message: e.message, data: d.data});
+        err.rpcCode = e.code;
+        return err;
+    }
+
+    xw.rpc = {
+        // call 调用 method, 返回 Promise.
+        call: function (method, params) {
+            if (RPC.failure) {
+                return Promise.reject(RPC.failure);
+            }
+            var r = rpcRequest(method, params, false);
+            send(r.req);
+            return r.promise;
+        },
+        // notify 调用 method, 不需要结果.
+        notify: function (method, params) {
+            send(rpcRequest(method, params, true).req);
+        },
+        // batch 把多个调用 {method, params, notify} 通过一条消息发送, 返回与 calls 对应的 Promise 数组, 通知对应 undefined.
+        batch: function (calls) {
+            var reqs = [];
+            var promises = [];
+            calls.forEach(function (c) {
+                var r = rpcRequest(c.method, c.params, c.notify);
+                reqs.push(r.req);
+                promises.push(r.promise);
+            });
+            if (reqs.length > 0) {
+                send(reqs);
+            }
+            return promises;
+        },
+    };
+
+    RPC.handlers.jsonrpc = function (m) {
+        var list = Array.isArray(m.data) ? m.data : [m.data];
+        list.forEach(function (resp) {
+            var p = rpcPending[resp.id];
+            if (!p) {
+                return;
+            }
+            delete rpcPending[resp.id];
+            if (resp.error) {
+                p.reject(rpcError(resp.error));
+            } else {
+                p.resolve(resp.result);
+            }
+        });
+    };
+
+    // errorInfo 把 js 异常转换为 Go 端的 JSError.
+    function errorInfo(e) {
+        if (!(e instanceof Error)) {
+            e = new Error(String(e));
+        }
+        return {name: e.name, message: e.message, stack: e.stack};
+    }
+
+    RPC.errorInfo = errorInfo;
+
+    // awaitEval 等待 Go 端 Eval 的脚本返回的 Promise, 结果通过 {evalResult: seq} 返回.
+    // 脚本是 Go 端执行的, 所以结果直接用 json 发送, 不等待握手, 握手失败时也会送达.
+    RPC.awaitEval = function (seq, promise) {
+        var reply = function (result, error) {
+            window.external.invoke(JSON.stringify({
+                evalResult: seq,
+                result: result === undefined ? null : result,
+                error: error,
+            }));
+        };
+        promise.then(function (v) {
+            try {
+                reply(v);
+            } catch (e) {
+                // 结果无法编码
+                reply(null, errorInfo(e));
+            }
+        }, function (e) {
+            reply(null, errorInfo(e));
+        });
+    };
+
+    // jsfunc 是 Go 端调用或释放 funcs 中的函数, 调用的结果通过 {callback: seq} 返回.
+    RPC.handlers.jsfunc = function (m) {
+        var fn = RPC.funcs[m.func];
+        if (m.release) {
+            delete RPC.funcs[m.func];
+            return;
+        }
+        var reply = function (result, error) {
+            send({
+                callback: m.seq,
+                result: result === undefined ? null : result,
+                error: error,
+            });
+        };
+        var fail = function (e) {
+            reply(null, errorInfo(e));
+        };
+        if (!fn) {
+            fail(new ReferenceError('function has been released'));
+            return;
+        }
+        try {
+            Promise.resolve(fn.apply(null, m.args || [])).then(function (v) {
+                try {
+                    reply(v);
+                } catch (e) {
+                    // 返回值无法转换为 json
+                    fail(e);
+                }
+            }, fail);
+        } catch (e) {
+            fail(e);
+        }
+    };
+
+    // protocolVersions 是 js 运行时支持的桥接协议版本, 与 Go 端的 ProtocolVersion 对应.
+    RPC.protocolVersions = [1];
+
+    // ready 在与 Go 端握手完成后解决为 {version, codec}, 协议版本或编码方式不兼容时被拒绝为 code 为 PROTOCOL 的 GoError.
+    // 在它之前调用的函数会排队, 握手完成后再发送, 握手失败时被拒绝.
+    var readyResolve, readyReject;
+    xw.ready = new Promise(function (resolve, reject) {
+        readyResolve = resolve;
+        readyReject = reject;
+    });
+    // 没有等待 ready 的页面不会出现未处理的拒绝
+    xw.ready.catch(function () {
+    });
+
+    // failQueued 拒绝握手前排队的调用.
+    function failQueued(queued, err) {
+        queued.forEach(function (m) {
+            var reqs = Array.isArray(m) ? m : [m];
+            reqs.forEach(function (r) {
+                if (r.jsonrpc !== undefined) {
+                    var p = rpcPending[r.id];
+                    if (p) {
+                        delete rpcPending[r.id];
+                        p.reject(err);
+                    }
+                } else if (r.id !== undefined) {
+                    settle(r.id, false, err);
+                }
+            });
+        });
+    }
+
+    RPC.handlers.welcome = function (m) {
+        if (RPC.state !== 'connecting') {
+            return;
+        }
+        var queued = outbox;
+        var received = inbox;
+        outbox = [];
+        inbox = [];
+        if (!m.error && !RPC.codecs[m.codec]) {
+            m.error = {code: 'PROTOCOL', message: 'unknown codec ' + m.codec};
+        }
+        if (m.error) {
+            RPC.state = 'failed';
+            RPC.failure = new GoError(m.error);
+            failQueued(queued, RPC.failure);
+            readyReject(RPC.failure);
+            return;
+        }
+        RPC.setCodec(m.codec);
+        RPC.protocol = m.version;
+        RPC.state = 'ready';
+        queued.forEach(function (q) {
+            try {
+                send(q);
+            } catch (e) {
+                // 参数无法编码
+                failQueued([q], e);
+            }
+        });
+        received.forEach(receive);
+        readyResolve({version: m.version, codec: m.codec});
+    };
+
+    // 页面加载时发送握手消息. 它总是 json, 在所有初始化脚本执行后发送, 这样通过 Init 注册的编码方式也能被协商.
+    setTimeout(function () {
+        window.external.invoke(JSON.stringify({
+            hello: {versions: RPC.protocolVersions, codecs: Object.keys(RPC.codecs)},
+        }));
+    }, 0);
+
+    RPC.bind = function (name) {
+        var p = lookup(name, true);
+        p.obj[p.key] = function () {
+            return RPC.call(name, arguments);
+        };
+    };
+
+    RPC.unbind = function (name) {
+        var p = lookup(name, false);
+        if (p) {
+            delete p.obj[p.key];
+        }
+    };
+})();
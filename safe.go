@@ -0,0 +1,174 @@
+package xwebview
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// SafeWebView 是可以在任意协程调用的 WebView, 由 WebView.Safe 返回.
+//
+// 每个方法都通过 DispatchSync 在UI线程执行并等待它完成, 在UI线程调用时直接执行.
+// WebView 已经销毁时返回 ErrWebViewDestroyed.
+type SafeWebView struct {
+	w *WebView
+}
+
+// Safe 返回可以在任意协程调用的 WebView.
+func (w *WebView) Safe() *SafeWebView {
+	return &SafeWebView{w: w}
+}
+
+// WebView 返回原来的 WebView.
+func (s *SafeWebView) WebView() *WebView {
+	return s.w
+}
+
+// Navigate 见 WebView.Navigate.
+func (s *SafeWebView) Navigate(url string) error {
+	return s.w.DispatchSync(func() error {
+		s.w.Navigate(url)
+		return nil
+	})
+}
+
+// SetHtml 见 WebView.SetHtml.
+func (s *SafeWebView) SetHtml(html string) error {
+	return s.w.DispatchSync(func() error {
+		s.w.SetHtml(html)
+		return nil
+	})
+}
+
+// SetTitle 见 WebView.SetTitle.
+func (s *SafeWebView) SetTitle(title string) error {
+	return s.w.DispatchSync(func() error {
+		s.w.SetTitle(title)
+		return nil
+	})
+}
+
+// SetSize 见 WebView.SetSize.
+func (s *SafeWebView) SetSize(width int, height int, hints Hint) error {
+	return s.w.DispatchSync(func() error {
+		s.w.SetSize(width, height, hints)
+		return nil
+	})
+}
+
+// Init 见 WebView.Init.
+func (s *SafeWebView) Init(js string) error {
+	return s.w.DispatchSync(func() error {
+		s.w.Init(js)
+		return nil
+	})
+}
+
+// Eval 见 WebView.Eval.
+func (s *SafeWebView) Eval(js string) error {
+	return s.w.DispatchSync(func() error {
+		s.w.Eval(js)
+		return nil
+	})
+}
+
+// Emit 见 WebView.Emit.
+func (s *SafeWebView) Emit(event string, payload interface{}) error {
+	return s.w.DispatchSync(func() error {
+		return s.w.Emit(event, payload)
+	})
+}
+
+// Bind 见 WebView.Bind.
+func (s *SafeWebView) Bind(name string, f interface{}, opt ...BindOption) error {
+	return s.w.DispatchSync(func() error {
+		return s.w.Bind(name, f, opt...)
+	})
+}
+
+// BindObject 见 WebView.BindObject.
+func (s *SafeWebView) BindObject(namespace string, obj interface{}, opt ...BindOption) error {
+	return s.w.DispatchSync(func() error {
+		return s.w.BindObject(namespace, obj, opt...)
+	})
+}
+
+// Unbind 见 WebView.Unbind.
+func (s *SafeWebView) Unbind(name string) error {
+	return s.w.DispatchSync(func() error {
+		return s.w.Unbind(name)
+	})
+}
+
+// UnbindObject 见 WebView.UnbindObject.
+func (s *SafeWebView) UnbindObject(namespace string) error {
+	return s.w.DispatchSync(func() error {
+		return s.w.UnbindObject(namespace)
+	})
+}
+
+// Refresh 见 WebView.Refresh.
+func (s *SafeWebView) Refresh(forceReload ...bool) error {
+	return s.w.DispatchSync(func() error {
+		s.w.Refresh(forceReload...)
+		return nil
+	})
+}
+
+// GoBack 见 WebView.GoBack.
+func (s *SafeWebView) GoBack() error {
+	return s.w.DispatchSync(func() error {
+		s.w.GoBack()
+		return nil
+	})
+}
+
+// GoForward 见 WebView.GoForward.
+func (s *SafeWebView) GoForward() error {
+	return s.w.DispatchSync(func() error {
+		s.w.GoForward()
+		return nil
+	})
+}
+
+// EvalAsync 见 WebView.EvalAsync.
+func (s *SafeWebView) EvalAsync(js string, f func(result interface{}, err error), timeout ...time.Duration) error {
+	return s.w.DispatchSync(func() error {
+		return s.w.EvalAsync(js, f, timeout...)
+	})
+}
+
+// EvalRaw 见 WebView.EvalRaw. 在协程调用时只在UI线程发起执行, 在当前协程等待结果, 不会阻塞UI线程.
+func (s *SafeWebView) EvalRaw(js string, opts ...EvalOption) (json.RawMessage, error) {
+	var o EvalOption
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return s.eval(js, o.timeout())
+}
+
+// EvalInto 见 WebView.EvalInto.
+func (s *SafeWebView) EvalInto(js string, out interface{}, opts ...EvalOption) error {
+	result, err := s.EvalRaw(js, opts...)
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result, out)
+}
+
+// EvalSync 见 WebView.EvalSync.
+func (s *SafeWebView) EvalSync(js string, timeout ...time.Duration) (interface{}, error) {
+	result, err := s.eval(js, evalTimeout(timeout))
+	if err != nil {
+		return nil, err
+	}
+	return decodeEval(result)
+}
+
+// eval 执行 js 表达式并等待结果. 在UI线程调用时与 WebView.EvalRaw 相同, 一边等待一边处理消息.
+func (s *SafeWebView) eval(js string, timeout time.Duration) (json.RawMessage, error) {
+	return s.w.evalContext(context.Background(), js, timeout)
+}
@@ -0,0 +1,112 @@
+package xwebview
+
+import (
+	"context"
+	"reflect"
+)
+
+// streamWindow 是 js 端未消费的流元素的最大数量, 达到后 Go 端暂停从通道中接收, 直到 js 端消费了一部分.
+const streamWindow = 16
+
+// streamMessage 是发送给 js 端的流元素, 流开始时 Open 为窗口大小, 流结束或出错时 Done 或 Error 不为空.
+//
+// 流的开始也通过 post 发送, 这样它与元素的顺序一致, js 端不会在流开始之前收到元素.
+type streamMessage struct {
+	Type  string       `json:"type"`
+	ID    int          `json:"id"`
+	Open  int          `json:"open,omitempty"`
+	Value interface{}  `json:"value"`
+	Done  bool         `json:"done,omitempty"`
+	Error *errorObject `json:"error,omitempty"`
+}
+
+// isStreamType 判断函数的返回值类型是否是可以接收的通道.
+func isStreamType(t reflect.Type) bool {
+	return t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0
+}
+
+// openStream 注册 id 对应的流, 返回接收 js 端发放的额度的通道.
+func (w *WebView) openStream(page uint64, id int) chan int {
+	credits := make(chan int, 8)
+	w.callsMux.Lock()
+	w.streams[callKey{page: page, id: id}] = credits
+	w.callsMux.Unlock()
+	return credits
+}
+
+// closeStream 删除 id 对应的流.
+func (w *WebView) closeStream(page uint64, id int) {
+	w.callsMux.Lock()
+	delete(w.streams, callKey{page: page, id: id})
+	w.callsMux.Unlock()
+}
+
+// addStreamCredit 处理 js 端消费了 n 个元素后发来的额度.
+func (w *WebView) addStreamCredit(id int, n int) {
+	w.callsMux.Lock()
+	credits := w.streams[callKey{page: w.page, id: id}]
+	w.callsMux.Unlock()
+	if credits == nil || n <= 0 {
+		return
+	}
+	select {
+	case credits <- n:
+	default:
+		// js 端最多只会有 streamWindow 个额度在途中, 不会阻塞
+	}
+}
+
+// runStream 从通道 ch 中接收元素并发送给 js 端, 直到通道关闭或 ctx 被取消. ch 为 nil 时是立即结束的空流. 在协程中执行.
+func (w *WebView) runStream(ctx context.Context, page uint64, id int, ch reflect.Value) {
+	credits := w.openStream(page, id)
+	defer w.closeStream(page, id)
+
+	send := func(msg streamMessage) (err error) {
+		w.ui(func() {
+			if w.isCurrentPage(page) {
+				err = w.post(msg)
+			}
+		})
+		return err
+	}
+	if err := send(streamMessage{Type: "stream", ID: id, Open: streamWindow}); err != nil {
+		return
+	}
+	// 从 nil 通道接收会永远阻塞, 当作空流
+	if !ch.IsValid() || ch.IsNil() {
+		_ = send(streamMessage{Type: "stream", ID: id, Done: true})
+		return
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(credits)},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	credit := streamWindow
+	for {
+		// 没有额度时不从通道中接收, 生产者会阻塞在发送上
+		active := cases
+		if credit == 0 {
+			active = cases[:2]
+		}
+		chosen, v, ok := reflect.Select(active)
+		switch chosen {
+		case 0:
+			return
+		case 1:
+			credit += int(v.Int())
+			continue
+		}
+		if !ok {
+			_ = send(streamMessage{Type: "stream", ID: id, Done: true})
+			return
+		}
+		if err := send(streamMessage{Type: "stream", ID: id, Value: v.Interface()}); err != nil {
+			obj := toErrorObject(NewError(ErrCodeBadResult, err.Error()))
+			_ = send(streamMessage{Type: "stream", ID: id, Error: &obj})
+			return
+		}
+		credit--
+	}
+}
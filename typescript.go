@@ -0,0 +1,24 @@
+package xwebview
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/twgh/xwebview/pkg/tsgen"
+)
+
+// WriteTypeScript 把已绑定的函数生成 TypeScript 声明写入 out, 可保存为 .d.ts 文件给前端使用. 类型转换规则见 tsgen 包.
+func (w *WebView) WriteTypeScript(out io.Writer) error {
+	g := tsgen.New()
+	for _, name := range w.ListBindings() {
+		b, ok := w.getBinding(name)
+		if !ok {
+			continue
+		}
+		if err := g.AddFuncType(name, reflect.TypeOf(b.f), b.description); err != nil {
+			return err
+		}
+	}
+	_, err := g.WriteTo(out)
+	return err
+}
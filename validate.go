@@ -0,0 +1,359 @@
+package xwebview
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrCodeValidation 是参数没有通过 validate 标签的验证, GoError 的 data 是 []FieldError.
+const ErrCodeValidation = "VALIDATION"
+
+// FieldError 是一个字段没有通过验证的原因, 在 js 端是 GoError.data 中的元素.
+type FieldError struct {
+	// Arg 是 js 端的参数的序号, 从0开始.
+	Arg int `json:"arg"`
+	// Field 是字段的路径, 使用 json 名称, 如 "address.city", "items[0].name".
+	Field string `json:"field"`
+	// Rule 是没有通过的规则, 如 "required", "min".
+	Rule string `json:"rule"`
+	// Param 是规则的参数, 如 min=3 中的 "3".
+	Param string `json:"param,omitempty"`
+	// Message 是错误信息.
+	Message string `json:"message"`
+}
+
+// ValidationError 是参数没有通过验证的错误, 错误码为 ErrCodeValidation.
+//
+// 绑定的函数的参数中的结构体字段可以用 validate 标签声明规则, 在函数执行前验证, 多条规则用逗号分隔:
+//   - required: 不能为零值, 指针不能为 nil
+//   - min=N, max=N: 数字的值, 字符串的字符数, 切片和 map 的元素个数的范围
+//   - len=N: 字符串的字符数, 切片和 map 的元素个数
+//   - regex=表达式: 字符串必须匹配正则表达式, 必须是最后一条规则, 表达式中可以有逗号
+//   - enum=a|b|c: 值必须是其中之一
+//
+// 不是 required 的字段为空字符串、空切片、空 map 或 nil 时, 跳过其他规则. 嵌套的结构体、切片和 map 中的结构体也会被验证.
+// 标签有误时 Bind 返回错误. 例如:
+//
+//	type User struct {
+//		Name  string `json:"name" validate:"required,max=20"`
+//		Age   int    `json:"age" validate:"min=0,max=150"`
+//		Role  string `json:"role" validate:"enum=admin|user"`
+//		Email string `json:"email" validate:"regex=^[^@]+@[^@]+$"`
+//	}
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	list := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		list = append(list, f.Field+": "+f.Message)
+	}
+	return "参数验证失败: " + strings.Join(list, "; ")
+}
+
+// ErrorCode 实现 CodedError.
+func (e *ValidationError) ErrorCode() string { return ErrCodeValidation }
+
+// ErrorData 实现 CodedError.
+func (e *ValidationError) ErrorData() interface{} { return e.Fields }
+
+// rule 是 validate 标签中的一条规则.
+type rule struct {
+	name  string
+	param string
+	num   float64
+	re    *regexp.Regexp
+	enum  []string
+}
+
+// fieldRules 是结构体中一个字段的规则.
+type fieldRules struct {
+	index    int
+	name     string
+	required bool
+	rules    []rule
+}
+
+// ruleCache 缓存结构体类型的规则, 值为 []fieldRules 或 error.
+var ruleCache sync.Map
+
+// structRules 返回结构体类型 t 的字段规则, 标签有误时返回错误.
+func structRules(t reflect.Type) ([]fieldRules, error) {
+	return buildRules(t, map[reflect.Type]bool{})
+}
+
+// buildRules 计算结构体类型 t 的字段规则, visiting 是正在计算的外层结构体类型.
+//
+// 字段的类型在 visiting 中时不再深入, 递归的类型不会无限循环. 规则计算完成后才放入 ruleCache,
+// 其他协程不会取到不完整的规则, 同时计算时结果相同.
+func buildRules(t reflect.Type, visiting map[reflect.Type]bool) ([]fieldRules, error) {
+	if v, ok := ruleCache.Load(t); ok {
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		return v.([]fieldRules), nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		// 匿名嵌入的结构体在 json 中被展开, 路径中不出现它的名字
+		if name == "" && !(sf.Anonymous && indirect(sf.Type).Kind() == reflect.Struct) {
+			name = sf.Name
+		}
+		f := fieldRules{index: i, name: name}
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			var err error
+			if f.required, f.rules, err = parseValidateTag(tag, sf.Type); err != nil {
+				err = errors.New(t.String() + "." + sf.Name + ": " + err.Error())
+				ruleCache.Store(t, err)
+				return nil, err
+			}
+		}
+		if err := checkType(sf.Type, visiting); err != nil {
+			ruleCache.Store(t, err)
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	ruleCache.Store(t, fields)
+	return fields, nil
+}
+
+// checkRules 检查类型 t 中所有结构体的 validate 标签.
+func checkRules(t reflect.Type) error {
+	return checkType(t, map[reflect.Type]bool{})
+}
+
+func checkType(t reflect.Type, visiting map[reflect.Type]bool) error {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return checkType(t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return nil
+		}
+		_, err := buildRules(t, visiting)
+		return err
+	}
+	return nil
+}
+
+// parseValidateTag 解析 validate 标签, 规则之间用逗号分隔, regex 必须是最后一条规则, 它的参数可以包含逗号.
+func parseValidateTag(tag string, t reflect.Type) (required bool, rules []rule, err error) {
+	t = indirect(t)
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regex=") {
+			item, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			item, tag = tag[:i], tag[i+1:]
+		} else {
+			item, tag = tag, ""
+		}
+		r := rule{name: item}
+		if i := strings.Index(item, "="); i >= 0 {
+			r.name, r.param = item[:i], item[i+1:]
+		}
+		switch r.name {
+		case "required":
+			required = true
+			continue
+		case "min", "max", "len":
+			if !isNumber(t.Kind()) && !hasLen(t.Kind()) {
+				return false, nil, errors.New(r.name + " 不能用于 " + t.String())
+			}
+			if r.num, err = strconv.ParseFloat(r.param, 64); err != nil {
+				return false, nil, errors.New(r.name + " 的参数必须是数字: " + r.param)
+			}
+			if r.name == "len" && !hasLen(t.Kind()) {
+				return false, nil, errors.New("len 不能用于 " + t.String())
+			}
+		case "regex":
+			if t.Kind() != reflect.String {
+				return false, nil, errors.New("regex 只能用于字符串")
+			}
+			if r.re, err = regexp.Compile(r.param); err != nil {
+				return false, nil, err
+			}
+		case "enum":
+			if r.param == "" {
+				return false, nil, errors.New("enum 缺少参数")
+			}
+			r.enum = strings.Split(r.param, "|")
+		default:
+			return false, nil, errors.New("未知的验证规则: " + r.name)
+		}
+		rules = append(rules, r)
+	}
+	return required, rules, nil
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func hasLen(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// validator 收集一个参数中所有字段的错误.
+type validator struct {
+	arg  int
+	errs []FieldError
+}
+
+func (v *validator) fail(path string, r rule, msg string) {
+	v.errs = append(v.errs, FieldError{Arg: v.arg, Field: path, Rule: r.name, Param: r.param, Message: msg})
+}
+
+// value 验证 rv 中所有结构体的字段.
+func (v *validator) value(rv reflect.Value, path string) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !rv.IsNil() {
+			v.value(rv.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			v.value(rv.Index(i), path+"["+strconv.Itoa(i)+"]")
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			v.value(iter.Value(), joinPath(path, fmt.Sprint(iter.Key().Interface())))
+		}
+	case reflect.Struct:
+		fields, err := structRules(rv.Type())
+		if err != nil {
+			return
+		}
+		for _, f := range fields {
+			fv := rv.Field(f.index)
+			p := joinPath(path, f.name)
+			if v.field(fv, p, f) {
+				v.value(fv, p)
+			}
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if name == "" {
+		return path
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// field 按规则验证一个字段, 字段为空时返回 false.
+func (v *validator) field(fv reflect.Value, path string, f fieldRules) bool {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			if f.required {
+				v.fail(path, rule{name: "required"}, "不能为空")
+			}
+			return false
+		}
+		fv = fv.Elem()
+	}
+	if fv.IsZero() {
+		if f.required {
+			v.fail(path, rule{name: "required"}, "不能为空")
+			return false
+		}
+		// 不是必填时, 空的字符串、切片和 map 跳过其他规则
+		if hasLen(fv.Kind()) {
+			return false
+		}
+	}
+
+	for _, r := range f.rules {
+		switch r.name {
+		case "min", "max", "len":
+			n, unit := measure(fv)
+			switch {
+			case r.name == "min" && n < r.num:
+				v.fail(path, r, unit+"不能小于 "+r.param)
+			case r.name == "max" && n > r.num:
+				v.fail(path, r, unit+"不能大于 "+r.param)
+			case r.name == "len" && n != r.num:
+				v.fail(path, r, "长度必须为 "+r.param)
+			}
+		case "regex":
+			if !r.re.MatchString(fv.String()) {
+				v.fail(path, r, "格式不正确")
+			}
+		case "enum":
+			s := fmt.Sprint(fv.Interface())
+			found := false
+			for _, e := range r.enum {
+				if e == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				v.fail(path, r, "必须是 "+strings.Join(r.enum, ", ")+" 之一")
+			}
+		}
+	}
+	return true
+}
+
+// measure 返回 min, max 比较的值: 数字是它的值, 字符串是字符数, 切片和 map 是元素个数.
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "长度"
+	}
+	return float64(v.Len()), "长度"
+}
+
+// validateArg 验证 js 端的第 i 个参数, 返回所有没有通过的字段.
+func validateArg(i int, arg reflect.Value) []FieldError {
+	v := validator{arg: i}
+	v.value(arg, "")
+	return v.errs
+}
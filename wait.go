@@ -0,0 +1,38 @@
+package xwebview
+
+import (
+	"github.com/twgh/xcgui/wapi"
+	"github.com/twgh/xwebview/internal/w32"
+	"github.com/twgh/xwebview/internal/wait"
+	"golang.org/x/sys/windows"
+)
+
+// ErrEvalReentrant 是在UI线程等待 js 的结果时, 处理消息的过程中又同步执行了 js, 如在 EvalSync 等待期间调用的函数中再调用 EvalSync.
+var ErrEvalReentrant = wait.ErrReentrant
+
+// waitUI 在UI线程处理消息直到 wt.Finish 被调用, 不占用CPU. 必须在UI线程执行.
+//
+// 收到 WM_QUIT 时重新投递它并返回 ErrWebViewDestroyed, 让外层的消息循环正常退出.
+func waitUI(wt *wait.Waiter, event windows.Handle) error {
+	handles := []windows.Handle{event}
+	block := func() (bool, error) {
+		r, err := w32.MsgWaitForMultipleObjectsEx(handles, w32.Infinite, w32.QSAllInput, w32.MWMOInputAvailable)
+		if err != nil {
+			return false, err
+		}
+		return r == w32.WaitObject0+1, nil
+	}
+	pump := func() error {
+		var msg wapi.MSG
+		for wapi.PeekMessage(&msg, 0, 0, 0, wapi.PM_REMOVE) {
+			if msg.Message == w32.WMQuit {
+				wapi.PostQuitMessage(int32(msg.WParam))
+				return ErrWebViewDestroyed
+			}
+			wapi.TranslateMessage(&msg)
+			wapi.DispatchMessage(&msg)
+		}
+		return nil
+	}
+	return wt.Wait(block, pump)
+}
@@ -1,6 +1,8 @@
 package xwebview
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +11,7 @@ import (
 	"github.com/twgh/xcgui/xcc"
 	"github.com/twgh/xwebview/pkg/edge"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +19,7 @@ import (
 	"unsafe"
 
 	"github.com/twgh/xwebview/internal/w32"
+	"github.com/twgh/xwebview/internal/wait"
 	"golang.org/x/sys/windows"
 )
 
@@ -43,13 +47,52 @@ type WebView struct {
 	maxsz     w32.Point
 	minsz     w32.Point
 	m         sync.Mutex
-	bindings  map[string]interface{}
+	bindings  map[string]*binding
 
 	hWindow           int // 炫彩窗口句柄
 	hParent           int
 	updateWebviewSize func()
-	evalCallbackMux   sync.Mutex
-	callbackID        int
+	uiThreadID        uint32 // 创建 WebView 的 UI 线程 ID
+
+	dispatchMux    sync.Mutex
+	dispatchQueue  []func()   // 等待在UI线程执行的函数, 见 Dispatch
+	dispatchPosted bool       // 已经投递了 wmDispatch, 尚未执行
+	evalGuard      wait.Guard // 防止 evalWait 重入, 只在UI线程访问
+
+	ctx        context.Context // WebView 销毁时取消
+	cancel     context.CancelFunc
+	callsMux   sync.Mutex
+	page       uint64 // 页面序号, 每次新文档开始加载时递增
+	pageCtx    context.Context
+	pageCancel context.CancelFunc
+	calls      map[callKey]context.CancelFunc
+	streams    map[callKey]chan int
+	jsCalls    map[int]chan jsFuncResult // 等待返回值的 JSFunc.Call
+	evalCalls  map[int]chan jsFuncResult // 等待 Promise 结果的 evalScript, 与 jsCalls 共用序号
+	jsCallID   int
+
+	panicHandler PanicHandler
+
+	pool *workerPool // 执行 ExecGoroutine 和 ExecSerial 的函数
+
+	allowedOrigins []string      // 允许调用绑定的函数的页面来源, 为空时不限制
+	navigationID   uint64        // 当前页面的导航 ID
+	protocol       int           // 当前页面握手协商的协议版本, 为0表示尚未握手
+	startedNavID   uint64        // 最近开始的导航的 ID
+	navStarted     uint64        // 已经开始的导航的数量
+	navLoaded      uint64        // 最近的导航完成时的 navStarted, 为0表示还没有导航完成过
+	loadErr        error         // 最近完成的导航的结果
+	loaded         chan struct{} // 每次页面加载完成时关闭并替换, 用于唤醒 WaitForLoad
+
+	middlewares []Middleware
+	codec       Codec    // 与 js 运行时之间的消息的编码方式
+	limiter     *limiter // 所有绑定的函数共用的限流器
+	metrics     *metrics // 绑定的函数的调用统计
+	tracer      CallTracer
+
+	eventsMux sync.Mutex
+	events    map[string][]eventHandler
+	eventID   int
 }
 
 // Hint 用于配置窗口大小和调整大小的行为。
@@ -69,41 +112,169 @@ const (
 	HintMax
 )
 
+// binding 是一个已绑定的 Go 函数.
+type binding struct {
+	f interface{}
+	// withContext 表示函数的第一个参数是 context.Context.
+	withContext bool
+	// injected 是 context.Context 之后由 xwebview 传入的参数个数, 如 *Caller.
+	injected int
+	// allowedOrigins 是允许调用的页面来源, 为空时不限制.
+	allowedOrigins []string
+	// stream 表示函数的第一个返回值是通道, 在js中是异步迭代器.
+	stream bool
+	// withJSFunc 表示函数的参数中可能有 JSFunc.
+	withJSFunc bool
+	// exec 是函数的执行方式, 不会是 ExecDefault.
+	exec ExecMode
+	// queue 是 ExecSerial 时的调用队列.
+	queue serialQueue
+	// limiter 是函数的限流器.
+	limiter *limiter
+	// description 是 BindOption.Description.
+	description string
+	// builtin 表示是 xwebview.bindings 等自带的函数, 不出现在 ListBindings 中.
+	builtin bool
+	// scriptID 是 Init 注入的脚本 ID, 为空表示尚未返回或没有注入.
+	scriptID string
+}
+
 type rpcMessage struct {
 	ID     int               `json:"id"`
 	Method string            `json:"method"`
 	Params []json.RawMessage `json:"params"`
+	// Cancel 是 js 端通过 AbortSignal 取消的调用 id.
+	Cancel int `json:"cancel,omitempty"`
+	// Stream 是 js 端消费了 Credit 个元素的流的调用 id.
+	Stream int `json:"stream,omitempty"`
+	Credit int `json:"credit,omitempty"`
+	// Event 是 js 端通过 window.xwebview.emit 发送的事件名, Data 是事件数据.
+	Event string          `json:"event,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	// Callback 是 JSFunc.Call 的序号, Result 和 Error 是 js 函数的返回值和异常.
+	Callback int             `json:"callback,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    *JSError        `json:"error,omitempty"`
+	// JSONRPC 不为空时是 JSON-RPC 2.0 的请求, 由 handleJSONRPC 处理.
+	JSONRPC string `json:"jsonrpc,omitempty"`
+	// Hello 是 js 运行时在页面加载时发送的握手消息.
+	Hello *helloMessage `json:"hello,omitempty"`
+
+	// caller 是发送消息的页面, 内部使用的调用为 nil.
+	caller *Caller
+	// progress 是函数的进度报告器, 为 nil 时丢弃进度.
+	progress *progress
+	// funcs 是参数中的 js 函数, 调用结束后释放没有交给函数的, 为 nil 时没有.
+	funcs *jsFuncRefs
 }
 
+// runtimeJS 是注入到每个页面的 js 运行时, 绑定的函数都依赖它.
+//
+//go:embed runtime.js
+var runtimeJS string
+
 func jsString(v interface{}) string { b, _ := json.Marshal(v); return string(b) }
 
-func (w *WebView) callbinding(d rpcMessage) (interface{}, error) {
+func (w *WebView) getBinding(name string) (*binding, bool) {
 	w.m.Lock()
-	f, ok := w.bindings[d.Method]
-	w.m.Unlock()
-	if !ok {
-		return nil, nil
+	defer w.m.Unlock()
+	b, ok := w.bindings[name]
+	return b, ok
+}
+
+// offset 返回 js 端的第一个参数在函数参数中的位置.
+func (b *binding) offset() int {
+	if b.withContext {
+		return 1 + b.injected
 	}
+	return b.injected
+}
 
-	v := reflect.ValueOf(f)
+// isInjected 判断类型为 t 的参数是否由 xwebview 传入.
+func isInjected(t reflect.Type) bool {
+	return t == callerType || t == progressType
+}
+
+// injectedArg 返回由 xwebview 传入的类型为 t 的参数.
+func injectedArg(t reflect.Type, d rpcMessage) reflect.Value {
+	switch t {
+	case callerType:
+		return reflect.ValueOf(d.caller)
+	case progressType:
+		p := d.progress
+		if p == nil {
+			p = &progress{}
+		}
+		v := reflect.New(progressType).Elem()
+		v.Set(reflect.ValueOf(p))
+		return v
+	}
+	return reflect.Zero(t)
+}
+
+// callbinding 经过 Middleware 调用绑定的函数.
+func (w *WebView) callbinding(ctx context.Context, b *binding, d rpcMessage) (result interface{}, err error) {
+	end := w.trace(b, d)
+	defer func() { end(err) }()
+	// 函数或 Middleware 发生 panic 时拒绝 js 端的 Promise
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, w.recoverPanic(d.Method, r)
+		}
+	}()
+	return w.intercept(ctx, b, d)
+}
+
+// invoke 把 js 端的参数转换为 Go 的值并调用函数.
+func (w *WebView) invoke(ctx context.Context, b *binding, d rpcMessage) (interface{}, error) {
+	v := reflect.ValueOf(b.f)
 	isVariadic := v.Type().IsVariadic()
 	numIn := v.Type().NumIn()
-	if (isVariadic && len(d.Params) < numIn-1) || (!isVariadic && len(d.Params) != numIn) {
-		return nil, errors.New("function arguments mismatch")
-	}
 	args := make([]reflect.Value, 0)
+	// 第一个参数是 context.Context 和之后的 *Caller 等参数由这里传入, 不占用 js 端的参数
+	offset := b.offset()
+	if b.withContext {
+		args = append(args, reflect.ValueOf(ctx))
+	}
+	for i := len(args); i < offset; i++ {
+		args = append(args, injectedArg(v.Type().In(i), d))
+	}
+	// 末尾连续的指针参数可以不传, 与 WriteTypeScript 生成的可选参数对应
+	fixed := numIn - offset
+	if isVariadic {
+		fixed--
+	}
+	required := fixed
+	for required > 0 && v.Type().In(offset+required-1).Kind() == reflect.Ptr {
+		required--
+	}
+	if len(d.Params) < required || (!isVariadic && len(d.Params) > fixed) {
+		return nil, NewError(ErrCodeInvalidArgs, "function arguments mismatch")
+	}
+	var fieldErrs []FieldError
+	var funcs []string
 	for i := range d.Params {
 		var arg reflect.Value
-		if isVariadic && i >= numIn-1 {
+		if isVariadic && i+offset >= numIn-1 {
 			arg = reflect.New(v.Type().In(numIn - 1).Elem())
 		} else {
-			arg = reflect.New(v.Type().In(i))
+			arg = reflect.New(v.Type().In(i + offset))
 		}
 		if err := json.Unmarshal(d.Params[i], arg.Interface()); err != nil {
-			return nil, err
+			return nil, NewError(ErrCodeInvalidArgs, "argument "+strconv.Itoa(i)+": "+err.Error())
 		}
+		w.attachJSFuncs(arg.Elem(), &funcs)
+		fieldErrs = append(fieldErrs, validateArg(i, arg.Elem())...)
 		args = append(args, arg.Elem())
 	}
+	if len(fieldErrs) > 0 {
+		return nil, &ValidationError{Fields: fieldErrs}
+	}
+	for i := len(d.Params); i < fixed; i++ {
+		args = append(args, reflect.Zero(v.Type().In(offset+i)))
+	}
+
+	d.funcs.keep(funcs)
 
 	errorType := reflect.TypeOf((*error)(nil)).Elem()
 	res := v.Call(args)
@@ -152,12 +323,16 @@ func wndproc(hwnd uintptr, msg uint32, wp, lp uintptr) uintptr {
 				w.browser.Focus()
 			}
 		case wapi.WM_CLOSE:
+			// 取消所有未完成的调用
+			w.cancel()
 			wapi.DestroyWindow(hwnd)
 			// 移除事件
 			xc.XWnd_RemoveEventC(w.hWindow, xcc.WM_SIZE, onWndSize)
 			xc.XEle_RemoveEventC(w.hParent, xcc.XE_SIZE, onEleSize)
 			xc.XEle_RemoveEventC(w.hParent, xcc.XE_SHOW, onEleShow)
 			xc.XEle_RemoveEventC(w.hParent, xcc.XE_DESTROY, onEleDestroy)
+		case wmDispatch:
+			w.drainDispatch()
 		case wapi.WM_GETMINMAXINFO:
 			lpmmi := (*w32.MinMaxInfo)(unsafe.Pointer(lp))
 			if w.maxsz.X > 0 && w.maxsz.Y > 0 {
@@ -248,11 +423,60 @@ func (w *WebView) Eval(js string) {
 // Bind 绑定一个Go函数，使其以给定的名称
 // 作为全局 JavaScript 函数出现。内部使用 webview_init()。必须在UI线程执行.
 //
+// name 中可以用点号分隔, 如 "app.getVersion" 会绑定为 window.app.getVersion.
+//
 // f 必须是一个函数:
-//   - 函数参数没什么限制
+//   - 函数参数没什么限制, 末尾连续的指针参数在 js 端可以不传, 不传时为 nil
 //   - 函数返回值可以是一个值或一个error
 //   - 函数返回值可以是一个值和一个error
-func (w *WebView) Bind(name string, f interface{}) error {
+//   - 返回的 error 不为 nil 时, js 端的 Promise 会被拒绝为带有 code, message 和 data 属性的 GoError, 见 Error 和 CodedError
+//   - 函数的第一个参数可以是 context.Context, 它不占用 js 端的参数, 在页面离开、WebView 销毁,
+//     或 js 端传入的最后一个参数 AbortSignal 触发时被取消. 这样的函数默认在协程池中执行, 不会阻塞UI线程, 见 BindOption.Exec.
+//   - 函数的第一个返回值可以是通道, 如 <-chan int, js 端拿到的是异步迭代器, 可以用 for await 逐个接收通道中的元素.
+//     js 端未消费的元素过多时会暂停接收, js 端 break 或页面离开时停止接收, 此时 context 被取消, 生产者应该监听它并退出.
+//     返回 nil 通道时 js 端是立即结束的空迭代器.
+//   - 函数可以声明 *Caller 参数获取调用者的来源等信息, 它不占用 js 端的参数, 见 Caller.
+//   - 函数可以声明 Progress 参数向 js 端报告进度, js 端用 Promise 的 onProgress 方法接收, 见 Progress.
+//   - js 端传入的函数参数在 Go 中可以用 JSFunc 接收并调用, 如报告进度.
+//   - 参数中的结构体字段可以用 validate 标签声明验证规则, 函数执行前验证, 见 ValidationError.
+//
+// 来自 iframe 或不在 BindOption.AllowedOrigins 和 XcWebViewOption.AllowedOrigins 中的来源的调用会被拒绝, 错误码为 ErrCodeForbidden.
+// 超出 BindOption.RateLimit 或 XcWebViewOption.RateLimit 的调用会被拒绝, 错误码为 ErrCodeRateLimited.
+//
+// 重复绑定同一个名称时, 会替换之前的函数.
+//
+// 已绑定的函数的名称、参数和返回值类型可以通过 Bindings 或 js 端的 xwebview.bindings() 获取, 见 BindingList.
+//
+// 绑定的函数也可以用 JSON-RPC 2.0 协议调用, js 端使用 xwebview.rpc.call(name, params), xwebview.rpc.notify 和 xwebview.rpc.batch,
+// 批量调用的结果通过一条消息返回. params 为对象时只能传给只有一个参数的函数, 返回通道的函数不能这样调用.
+//
+// opt: 选项, 可不填.
+func (w *WebView) Bind(name string, f interface{}, opt ...BindOption) error {
+	var o BindOption
+	if len(opt) > 0 {
+		o = opt[0]
+	}
+	return w.bind(name, f, o)
+}
+
+// BindOption 是 Bind 和 BindObject 的选项.
+type BindOption struct {
+	// Exec 是函数的执行方式, 默认为 ExecDefault.
+	Exec ExecMode
+	// AllowedOrigins 是允许调用该函数的页面来源, 为空时不限制, 与 XcWebViewOption.AllowedOrigins 同时生效.
+	//
+	// 可以是完整的来源 "https://example.com", 带通配符的子域名 "https://*.example.com" 或 "*.example.com",
+	// 只有主机名的 "app.local" (匹配任意协议, 适用于虚拟主机名映射), "null" (about:blank, data: 等没有来源的页面), 或 "*" (任意来源).
+	// 模式中没有端口时匹配任意端口, 协议的默认端口与不写端口相同. "*.example.com" 不匹配 example.com 本身.
+	AllowedOrigins []string
+	// RateLimit 限制该函数的调用频率和并发数, 默认不限制, 与 XcWebViewOption.RateLimit 同时生效.
+	RateLimit RateLimit
+	// Description 是函数的说明, 会出现在 Bindings 和 js 端的 xwebview.bindings() 的结果中, 以及 WriteTypeScript 生成的注释中.
+	Description string
+}
+
+// bind 绑定函数, 并注入到当前页面和之后打开的页面.
+func (w *WebView) bind(name string, f interface{}, opt BindOption) error {
 	v := reflect.ValueOf(f)
 	if v.Kind() != reflect.Func {
 		return errors.New("only functions can be bound")
@@ -260,34 +484,104 @@ func (w *WebView) Bind(name string, f interface{}) error {
 	if n := v.Type().NumOut(); n > 2 {
 		return errors.New("function may only return a value or a value+error")
 	}
+	b := &binding{f: f}
+	b.withContext = v.Type().NumIn() > 0 && v.Type().In(0) == contextType
+	for i := b.offset(); i < v.Type().NumIn() && isInjected(v.Type().In(i)); i++ {
+		if v.Type().IsVariadic() && i == v.Type().NumIn()-1 {
+			break
+		}
+		b.injected++
+	}
+	b.allowedOrigins = opt.AllowedOrigins
+	// 提前检查参数中的 validate 标签, 标签有误时绑定失败
+	for i := b.offset(); i < v.Type().NumIn(); i++ {
+		if err := checkRules(v.Type().In(i)); err != nil {
+			return err
+		}
+		if hasJSFunc(v.Type().In(i), map[reflect.Type]bool{}) {
+			b.withJSFunc = true
+		}
+	}
+	b.stream = v.Type().NumOut() > 0 && isStreamType(v.Type().Out(0))
+	b.exec = opt.Exec.resolve(b)
+	b.limiter = newLimiter("binding", opt.RateLimit)
+	b.description = opt.Description
+	var oldScriptID string
 	w.m.Lock()
-	w.bindings[name] = f
+	if old := w.bindings[name]; old != nil {
+		oldScriptID = old.scriptID
+	}
+	w.bindings[name] = b
 	w.m.Unlock()
+	if oldScriptID != "" {
+		w.browser.RemoveScriptToExecuteOnDocumentCreated(oldScriptID)
+	}
 
-	initCode := "(function() { var name = " + jsString(name) + ";" + `
-		var RPC = window._rpc = (window._rpc || {nextSeq: 1});
-		window[name] = function() {
-		  var seq = RPC.nextSeq++;
-		  var promise = new Promise(function(resolve, reject) {
-			RPC[seq] = {
-			  resolve: resolve,
-			  reject: reject,
-			};
-		  });
-		  window.external.invoke(JSON.stringify({
-			id: seq,
-			method: name,
-			params: Array.prototype.slice.call(arguments),
-		  }));
-		  return promise;
+	initCode := "window._rpc.bind(" + jsString(name) + ");"
+	w.browser.Eval(initCode)
+	w.browser.AddScriptToExecuteOnDocumentCreated(initCode, func(id string, err error) {
+		if err != nil {
+			return
 		}
-	})()`
+		w.m.Lock()
+		current := w.bindings[name] == b
+		if current {
+			b.scriptID = id
+		}
+		w.m.Unlock()
+		// 在脚本 ID 返回之前已经被解绑或替换了
+		if !current {
+			w.browser.RemoveScriptToExecuteOnDocumentCreated(id)
+		}
+	})
+	return nil
+}
 
-	w.browser.Eval(initCode)
-	w.Init(initCode)
+// Unbind 解除绑定的函数, 会删除页面中的 window[name], 并移除 Bind 注入的初始化脚本. 必须在UI线程执行.
+//
+// name: 绑定时的名称.
+func (w *WebView) Unbind(name string) error {
+	var scriptID string
+	w.m.Lock()
+	b, ok := w.bindings[name]
+	if ok {
+		scriptID = b.scriptID
+		delete(w.bindings, name)
+	}
+	w.m.Unlock()
+	if !ok {
+		return ErrBindingNotFound
+	}
+
+	if scriptID != "" {
+		w.browser.RemoveScriptToExecuteOnDocumentCreated(scriptID)
+	}
+	w.browser.Eval("window._rpc.unbind(" + jsString(name) + ");")
 	return nil
 }
 
+// HasBinding 判断是否绑定了指定名称的函数.
+func (w *WebView) HasBinding(name string) bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+	_, ok := w.bindings[name]
+	return ok
+}
+
+// ListBindings 返回已绑定的函数名称, 按名称排序, 不包括 xwebview 自带的函数.
+func (w *WebView) ListBindings() []string {
+	w.m.Lock()
+	names := make([]string, 0, len(w.bindings))
+	for name, b := range w.bindings {
+		if !b.builtin {
+			names = append(names, name)
+		}
+	}
+	w.m.Unlock()
+	sort.Strings(names)
+	return names
+}
+
 // GetHWND 返回 webview 所在的原生窗口句柄.
 func (w *WebView) GetHWND() uintptr {
 	return w.hwnd
@@ -299,162 +593,57 @@ func (w *WebView) GetBrowser() *edge.Chromium {
 
 // EvalAsync 执行 js 代码, 可在回调函数中异步获取结果. 必须在UI线程执行.
 //
-// js: js 代码.
+// js: js 表达式, 值是 Promise 时等待它完成. 脚本通过 ExecuteScript 执行, 不会在 window 上添加函数, 也不占用绑定的函数.
 //
 // f: 可在回调函数中获取js代码执行结果以及错误, 为 nil 时, 等同于执行了 Eval. 注意这个回调函数是在协程中执行的, 不是在UI线程.
+// js 抛出异常或 Promise 被拒绝时, err 为 *JSError.
 //
-// timeout: 超时时间, 为空默认10秒.
+// timeout: 超时时间, 为空默认10秒. 需要用 context.Context 取消时使用 EvalAsyncContext.
 func (w *WebView) EvalAsync(js string, f func(result interface{}, err error), timeout ...time.Duration) error {
 	if f == nil {
 		w.browser.Eval(js)
 		return nil
 	}
+	w.evalScript(context.Background(), js, evalTimeout(timeout), func(result json.RawMessage, err error) {
+		if err != nil {
+			f(nil, err)
+			return
+		}
+		f(decodeEval(result))
+	})
+	return nil
+}
 
-	resultChan := make(chan interface{}, 1)
-	w.evalCallbackMux.Lock()
-	w.callbackID++
-	callbackName := "__go_eval2_cb_" + strconv.Itoa(w.callbackID)
-	w.evalCallbackMux.Unlock()
-
-	// 绑定临时回调
-	if err := w.Bind(callbackName, func(r interface{}) {
-		resultChan <- r
-		// 删除绑定的js函数
-		w.browser.Eval(fmt.Sprintf("delete window.%s;", callbackName))
-	}); err != nil {
-		return err
-	}
-
-	// 执行脚本
-	wrappedJS := fmt.Sprintf(`
-	    (function() {
-	        try {
-	            const result = (%s);
-	            if (result instanceof Promise) {
-	                result.then(
-	                    res => window.%s(res),
-	                    err => window.%s({ error: err.message })
-	                );
-	            } else {
-	                window.%s(result);
-	            }
-	        } catch (e) {
-	            window.%s({ error: e.message });
-	        }
-	    })();
-	`, strings.TrimRight(js, ";"), callbackName, callbackName, callbackName, callbackName)
-
-	w.browser.Eval(wrappedJS)
-
-	// 超时时间默认10秒
-	t := 10 * time.Second
+// evalTimeout 返回 Eval 的超时时间, 默认10秒.
+func evalTimeout(timeout []time.Duration) time.Duration {
 	if len(timeout) > 0 {
-		t = timeout[0]
+		return timeout[0]
 	}
-
-	// 异步处理结果
-	go func() {
-		select {
-		case ret := <-resultChan:
-			close(resultChan)
-			if errMap, ok := ret.(map[string]interface{}); ok && errMap["error"] != nil {
-				f(nil, fmt.Errorf("JS错误: %v", errMap["error"]))
-			} else {
-				f(ret, nil)
-			}
-		case <-time.After(t):
-			close(resultChan)
-			f(nil, ErrEvalTimeout)
-		}
-	}()
-	return nil
+	return 10 * time.Second
 }
 
 var (
-	// ErrEvalTimeout 是执行 js 代码超时.
-	ErrEvalTimeout = errors.New("执行超时")
+	// ErrEvalTimeout 是执行 js 代码超时, 它满足 errors.Is(err, context.DeadlineExceeded).
+	ErrEvalTimeout error = timeoutError{}
+	// ErrBindingNotFound 是没有找到绑定的函数.
+	ErrBindingNotFound = errors.New("没有找到绑定的函数")
 )
 
 // EvalSync 执行 js 代码, 同步取回返回值. 必须在UI线程执行.
 //
-// js: js 代码.
+// js: js 表达式, 值是 Promise 时等待它完成.
 //
 // timeout: 超时时间, 为空默认10秒.
+//
+// js 抛出异常或 Promise 被拒绝时返回 *JSError. 返回值经过 map[string]interface{} 等转换, 数字都是 float64,
+// 需要具体的类型时使用 EvalInto 或 EvalRaw. 等待期间的限制见 EvalRaw.
+// 需要用 context.Context 取消时使用 EvalContext.
 func (w *WebView) EvalSync(js string, timeout ...time.Duration) (interface{}, error) {
-	resultChan := make(chan interface{}, 1)
-	w.evalCallbackMux.Lock()
-	w.callbackID++
-	callbackName := "__go_EvalSync_cb_" + strconv.Itoa(w.callbackID)
-	w.evalCallbackMux.Unlock()
-
-	var err error
-	// 绑定临时回调
-	if err = w.Bind(callbackName, func(r interface{}) {
-		resultChan <- r
-		// 删除绑定的js函数
-		w.browser.Eval(fmt.Sprintf("delete window.%s;", callbackName))
-	}); err != nil {
+	result, err := w.evalWait(context.Background(), js, evalTimeout(timeout))
+	if err != nil {
 		return nil, err
 	}
-
-	// 执行脚本
-	wrappedJS := fmt.Sprintf(`
-	    (function() {
-	        try {
-	            const result = (%s);
-	            if (result instanceof Promise) {
-	                result.then(
-	                    res => window.%s(res),
-	                    err => window.%s({ error: err.message })
-	                );
-	            } else {
-	                window.%s(result);
-	            }
-	        } catch (e) {
-	            window.%s({ error: e.message });
-	        }
-	    })();
-	`, strings.TrimRight(js, ";"), callbackName, callbackName, callbackName, callbackName)
-
-	w.browser.Eval(wrappedJS)
-
-	// 超时时间默认10秒
-	t := 10 * time.Second
-	if len(timeout) > 0 {
-		t = timeout[0]
-	}
-
-	// 等待结果
-	var isDone bool
-	var result interface{}
-	go func() {
-		select {
-		case ret := <-resultChan:
-			if errMap, ok := ret.(map[string]interface{}); ok && errMap["error"] != nil {
-				err = fmt.Errorf("JS错误: %v", errMap["error"])
-			} else {
-				result = ret
-				err = nil
-			}
-		case <-time.After(t):
-			err = ErrEvalTimeout
-		}
-		close(resultChan)
-		isDone = true
-	}()
-
-	// 启动消息循环处理
-	var msg wapi.MSG
-	for !isDone {
-		hasMessage := wapi.PeekMessage(&msg, 0, 0, 0, wapi.PM_REMOVE)
-		if hasMessage {
-			wapi.TranslateMessage(&msg)
-			wapi.DispatchMessage(&msg)
-		} else {
-			wapi.Sleep(1) // 避免 CPU 空转
-		}
-	}
-	return result, err
+	return decodeEval(result)
 }
 
 // Refresh 网页_刷新.